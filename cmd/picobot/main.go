@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -17,12 +20,15 @@ import (
 
 	"github.com/local/picobot/internal/agent"
 	"github.com/local/picobot/internal/agent/memory"
+	"github.com/local/picobot/internal/agent/tools"
 	"github.com/local/picobot/internal/channels"
 	"github.com/local/picobot/internal/chat"
 	"github.com/local/picobot/internal/config"
 	"github.com/local/picobot/internal/cron"
 	"github.com/local/picobot/internal/heartbeat"
 	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/trace"
+	"github.com/local/picobot/internal/usage"
 )
 
 const version = "0.1.0"
@@ -93,6 +99,80 @@ func NewRootCmd() *cobra.Command {
 		},
 	})
 
+	onboardOllamaCmd := &cobra.Command{
+		Use:   "ollama",
+		Short: "Point picobot at a local Ollama server and pick an installed model",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, err := config.LoadConfig()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "failed to load config: %v\n", err)
+				return
+			}
+			if cfg.Agents.Defaults.Workspace == "" {
+				fmt.Fprintln(cmd.ErrOrStderr(), "no config found; run 'picobot onboard' first")
+				return
+			}
+			base, _ := cmd.Flags().GetString("base")
+			if base == "" && cfg.Providers.Ollama != nil {
+				base = cfg.Providers.Ollama.APIBase
+			}
+			ollama := providers.NewOllamaProvider(base, 10)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			models, err := ollama.ListModels(ctx)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "could not list models from %s: %v\n", ollama.APIBase, err)
+				return
+			}
+			if len(models) == 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "no models installed on %s; run 'ollama pull <model>' first\n", ollama.APIBase)
+				return
+			}
+
+			model, _ := cmd.Flags().GetString("model")
+			if model == "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Models installed on %s:\n", ollama.APIBase)
+				for i, m := range models {
+					fmt.Fprintf(cmd.OutOrStdout(), "  %d) %s  %s %s  %.1f GB\n", i+1, m.Name, m.ParameterSize, m.Quantization, float64(m.Size)/1e9)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Choose a model [1-%d] (default 1): ", len(models))
+				line, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				choice := 1
+				if line = strings.TrimSpace(line); line != "" {
+					n, err := strconv.Atoi(line)
+					if err != nil || n < 1 || n > len(models) {
+						fmt.Fprintln(cmd.ErrOrStderr(), "invalid choice:", line)
+						return
+					}
+					choice = n
+				}
+				model = models[choice-1].Name
+			}
+
+			oc := cfg.Providers.Ollama
+			if oc == nil {
+				oc = &config.OllamaConfig{}
+			}
+			oc.APIBase = ollama.APIBase
+			cfg.Providers.Ollama = oc
+			cfg.Agents.Defaults.Model = model
+			cfgPath, _, err := config.ResolveDefaultPaths()
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "failed to resolve config path: %v\n", err)
+				return
+			}
+			if err := config.SaveConfig(cfg, cfgPath); err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "could not save config: %v\n", err)
+				return
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Config updated: ollama at %s, model %s\n", ollama.APIBase, model)
+		},
+	}
+	onboardOllamaCmd.Flags().String("base", "", "Ollama server URL (default http://localhost:11434)")
+	onboardOllamaCmd.Flags().String("model", "", "Model to use, skipping the interactive choice")
+	onboardCmd.AddCommand(onboardOllamaCmd)
+
 	rootCmd.AddCommand(onboardCmd)
 
 	agentCmd := &cobra.Command{
@@ -108,11 +188,10 @@ func NewRootCmd() *cobra.Command {
 
 			hub := chat.NewHub(100)
 			cfg, _ := config.LoadConfig()
-			var provider providers.LLMProvider
-			if cfg.Providers.OpenAI != nil && cfg.Providers.OpenAI.APIKey != "" {
-				provider = providers.NewOpenAIProvider(cfg.Providers.OpenAI.APIKey, cfg.Providers.OpenAI.APIBase, cfg.Agents.Defaults.RequestTimeoutS)
-			} else {
-				provider = providers.NewStubProvider()
+			provider, err := providerFor(cmd, cfg)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				return
 			}
 
 			// choose model: flag > config default > provider default
@@ -128,7 +207,25 @@ func NewRootCmd() *cobra.Command {
 			if maxIter <= 0 {
 				maxIter = 100
 			}
-			ag := agent.NewAgentLoop(hub, provider, model, maxIter, cfg.Agents.Defaults.Workspace, nil)
+			ws := workspaceDir(cfg)
+			ag := agent.NewAgentLoop(hub, provider, model, maxIter, ws, nil)
+			if modelFlag != "" {
+				// an explicit --model beats the cli channel profile
+				delete(cfg.Agents.ChannelProfiles, "cli")
+			}
+			applyProfiles(ag, cfg)
+			approval, err := tools.NewApprovalPolicy(cfg.Agents.Approval)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				return
+			}
+			ag.SetApprovalPolicy(approval)
+			ag.SetContextWindow(contextWindow(cfg, provider, model), cfg.Agents.Defaults.MaxTokens)
+			ag.SetUsageTracker(usage.NewTracker(ws))
+			ag.SetTracer(trace.NewTracer(ws))
+			if cfg.Agents.Defaults.LogReasoning {
+				ag.SetReasoningLog(filepath.Join(ws, "reasoning"))
+			}
 
 			resp, err := ag.ProcessDirect(msg, 60*time.Second)
 			if err != nil {
@@ -140,6 +237,7 @@ func NewRootCmd() *cobra.Command {
 	}
 	agentCmd.Flags().StringP("message", "m", "", "Message to send to the agent")
 	agentCmd.Flags().StringP("model", "M", "", "Model to use (overrides config/provider default)")
+	agentCmd.Flags().String("provider", "", "Override the configured provider: stub or script:<scenario.json>")
 	rootCmd.AddCommand(agentCmd)
 
 	gatewayCmd := &cobra.Command{
@@ -148,7 +246,11 @@ func NewRootCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			hub := chat.NewHub(200)
 			cfg, _ := config.LoadConfig()
-			provider := providers.NewProviderFromConfig(cfg)
+			provider, err := providerFor(cmd, cfg)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				return
+			}
 
 			// choose model: flag > config > provider default
 			modelFlag, _ := cmd.Flags().GetString("model")
@@ -163,19 +265,45 @@ func NewRootCmd() *cobra.Command {
 			// create scheduler with fire callback that routes back through the agent loop, so the LLM can process the reminder and respond naturally to the user.
 			scheduler := cron.NewScheduler(func(job cron.Job) {
 				log.Printf("cron fired: %s — %s", job.Name, job.Message)
-				hub.In <- chat.Inbound{
+				profile := job.Profile
+				if profile == "" {
+					profile = cfg.Agents.ChannelProfiles["cron"]
+				}
+				in := chat.Inbound{
 					Channel:  job.Channel,
 					SenderID: "cron",
 					ChatID:   job.ChatID,
 					Content:  fmt.Sprintf("[Scheduled reminder fired] %s — Please relay this to the user in a friendly way.", job.Message),
 				}
+				if profile != "" {
+					in.Metadata = map[string]interface{}{"profile": profile}
+				}
+				hub.In <- in
 			})
 
 			maxIter := cfg.Agents.Defaults.MaxToolIterations
 			if maxIter <= 0 {
 				maxIter = 100
 			}
-			ag := agent.NewAgentLoop(hub, provider, model, maxIter, cfg.Agents.Defaults.Workspace, scheduler)
+			ws := workspaceDir(cfg)
+			ag := agent.NewAgentLoop(hub, provider, model, maxIter, ws, scheduler)
+			applyProfiles(ag, cfg)
+			approval, err := tools.NewApprovalPolicy(cfg.Agents.Approval)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				return
+			}
+			ag.SetApprovalPolicy(approval)
+			ag.SetStreaming(cfg.Agents.Defaults.Streaming)
+			ag.SetVision(cfg.Agents.Defaults.Vision)
+			ag.SetMaxConcurrency(cfg.Agents.Defaults.MaxConcurrency)
+			ag.SetInterruptOnNewMessage(cfg.Agents.Defaults.InterruptOnNewMessage)
+			ag.SetContextWindow(contextWindow(cfg, provider, model), cfg.Agents.Defaults.MaxTokens)
+			ag.SetUsageTracker(usage.NewTracker(ws))
+			ag.SetTracer(trace.NewTracer(ws))
+			if cfg.Agents.Defaults.LogReasoning {
+				ag.SetReasoningLog(filepath.Join(ws, "reasoning"))
+			}
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
@@ -190,18 +318,18 @@ func NewRootCmd() *cobra.Command {
 			if hbInterval <= 0 {
 				hbInterval = 60 * time.Second
 			}
-			heartbeat.StartHeartbeat(ctx, cfg.Agents.Defaults.Workspace, hbInterval, hub)
+			heartbeat.StartHeartbeat(ctx, ws, hbInterval, hub)
 
 			// start telegram if enabled
 			if cfg.Channels.Telegram.Enabled {
-				if err := channels.StartTelegram(ctx, hub, cfg.Channels.Telegram.Token, cfg.Channels.Telegram.AllowFrom); err != nil {
+				if err := channels.StartTelegram(ctx, hub, cfg.Channels.Telegram.Token, cfg.Channels.Telegram.AllowFrom, ag.Commands()); err != nil {
 					fmt.Fprintf(os.Stderr, "failed to start telegram: %v\n", err)
 				}
 			}
 
 			// start discord if enabled
 			if cfg.Channels.Discord.Enabled {
-				if err := channels.StartDiscord(ctx, hub, cfg.Channels.Discord.Token, cfg.Channels.Discord.AllowFrom); err != nil {
+				if err := channels.StartDiscord(ctx, hub, cfg.Channels.Discord.Token, cfg.Channels.Discord.AllowFrom, ag.Commands()); err != nil {
 					fmt.Fprintf(os.Stderr, "failed to start discord: %v\n", err)
 				}
 			}
@@ -237,6 +365,7 @@ func NewRootCmd() *cobra.Command {
 		},
 	}
 	gatewayCmd.Flags().StringP("model", "M", "", "Model to use (overrides config/provider default)")
+	gatewayCmd.Flags().String("provider", "", "Override the configured provider: stub or script:<scenario.json>")
 	rootCmd.AddCommand(gatewayCmd)
 
 	// memory subcommands: read, append, write, recent
@@ -252,14 +381,7 @@ func NewRootCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			target := args[0]
 			cfg, _ := config.LoadConfig()
-			ws := cfg.Agents.Defaults.Workspace
-			if ws == "" {
-				ws = "~/.picobot/workspace"
-			}
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(ws, "~/") {
-				ws = filepath.Join(home, ws[2:])
-			}
+			ws := workspaceDir(cfg)
 			mem := memory.NewMemoryStoreWithWorkspace(ws, 100)
 			switch target {
 			case "today":
@@ -286,14 +408,7 @@ func NewRootCmd() *cobra.Command {
 				return
 			}
 			cfg, _ := config.LoadConfig()
-			ws := cfg.Agents.Defaults.Workspace
-			if ws == "" {
-				ws = "~/.picobot/workspace"
-			}
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(ws, "~/") {
-				ws = filepath.Join(home, ws[2:])
-			}
+			ws := workspaceDir(cfg)
 			mem := memory.NewMemoryStoreWithWorkspace(ws, 100)
 			switch target {
 			case "today":
@@ -335,14 +450,7 @@ func NewRootCmd() *cobra.Command {
 				return
 			}
 			cfg, _ := config.LoadConfig()
-			ws := cfg.Agents.Defaults.Workspace
-			if ws == "" {
-				ws = "~/.picobot/workspace"
-			}
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(ws, "~/") {
-				ws = filepath.Join(home, ws[2:])
-			}
+			ws := workspaceDir(cfg)
 			mem := memory.NewMemoryStoreWithWorkspace(ws, 100)
 			if err := mem.WriteLongTerm(content); err != nil {
 				fmt.Fprintln(cmd.ErrOrStderr(), "write failed:", err)
@@ -359,14 +467,7 @@ func NewRootCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			days, _ := cmd.Flags().GetInt("days")
 			cfg, _ := config.LoadConfig()
-			ws := cfg.Agents.Defaults.Workspace
-			if ws == "" {
-				ws = "~/.picobot/workspace"
-			}
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(ws, "~/") {
-				ws = filepath.Join(home, ws[2:])
-			}
+			ws := workspaceDir(cfg)
 			mem := memory.NewMemoryStoreWithWorkspace(ws, 100)
 			out, _ := mem.GetRecentMemories(days)
 			fmt.Fprintln(cmd.OutOrStdout(), out)
@@ -392,14 +493,7 @@ func NewRootCmd() *cobra.Command {
 			top, _ := cmd.Flags().GetInt("top")
 			verbose, _ := cmd.Flags().GetBool("verbose")
 			cfg, _ := config.LoadConfig()
-			ws := cfg.Agents.Defaults.Workspace
-			if ws == "" {
-				ws = "~/.picobot/workspace"
-			}
-			home, _ := os.UserHomeDir()
-			if strings.HasPrefix(ws, "~/") {
-				ws = filepath.Join(home, ws[2:])
-			}
+			ws := workspaceDir(cfg)
 			mem := memory.NewMemoryStoreWithWorkspace(ws, 100)
 			// Build memory items from today's file (split into lines) and long-term memory
 			items := make([]memory.MemoryItem, 0)
@@ -443,9 +537,186 @@ func NewRootCmd() *cobra.Command {
 	memoryCmd.AddCommand(rankCmd)
 
 	rootCmd.AddCommand(memoryCmd)
+
+	usageCmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Report token usage and estimated cost",
+		Run: func(cmd *cobra.Command, args []string) {
+			days, _ := cmd.Flags().GetInt("days")
+			by, _ := cmd.Flags().GetString("by")
+			keyFn, ok := usage.KeyFuncs[by]
+			if !ok {
+				fmt.Fprintln(cmd.ErrOrStderr(), "unknown --by value (use chat, channel, model or day):", by)
+				return
+			}
+			cfg, _ := config.LoadConfig()
+			ws := workspaceDir(cfg)
+			records, err := usage.Load(ws, days)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "reading usage failed:", err)
+				return
+			}
+			if len(records) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "no usage recorded")
+				return
+			}
+			prices := make(map[string]usage.Price, len(cfg.Pricing))
+			for m, p := range cfg.Pricing {
+				prices[m] = usage.Price{InputPerMTok: p.InputPerMTok, OutputPerMTok: p.OutputPerMTok}
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "%s\tTURNS\tPROMPT\tCOMPLETION\tCOST (USD)\n", strings.ToUpper(by))
+			for _, t := range usage.Summarize(records, keyFn, prices) {
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", t.Key, t.Turns, t.PromptTokens, t.CompletionTokens, formatCost(t))
+			}
+			// summarized separately: a turn can span several models or days
+			all := usage.Summarize(records, func(usage.Record) string { return "TOTAL" }, prices)[0]
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", all.Key, all.Turns, all.PromptTokens, all.CompletionTokens, formatCost(all))
+			tw.Flush()
+			if all.Unpriced {
+				fmt.Fprintln(cmd.OutOrStdout(), "* some models have no entry in config \"pricing\"; their cost is not included")
+			}
+		},
+	}
+	usageCmd.Flags().IntP("days", "d", 30, "Number of days to include (0 = all)")
+	usageCmd.Flags().StringP("by", "b", "chat", "Group by: chat, channel, model or day")
+	rootCmd.AddCommand(usageCmd)
+
+	traceCmd := &cobra.Command{
+		Use:   "trace",
+		Short: "Inspect recorded agent turns",
+	}
+	traceShowCmd := &cobra.Command{
+		Use:   "show [turn-id]",
+		Short: "Show a turn as a timeline (default: the latest turn)",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, _ := config.LoadConfig()
+			ws := workspaceDir(cfg)
+			turnID := ""
+			if len(args) == 1 {
+				turnID = args[0]
+			} else {
+				id, err := trace.LastTurnID(ws)
+				if err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), "reading traces failed:", err)
+					return
+				}
+				turnID = id
+			}
+			if turnID == "" {
+				fmt.Fprintln(cmd.OutOrStdout(), "no traces recorded")
+				return
+			}
+			events, err := trace.LoadTurn(ws, turnID)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), "reading traces failed:", err)
+				return
+			}
+			if len(events) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "no trace for turn", turnID)
+				return
+			}
+			trace.Render(cmd.OutOrStdout(), events)
+		},
+	}
+	traceCmd.AddCommand(traceShowCmd)
+	rootCmd.AddCommand(traceCmd)
 	return rootCmd
 }
 
+// providerFor returns the provider named by the --provider flag, or the one
+// selected by the config when the flag is not set.
+func providerFor(cmd *cobra.Command, cfg config.Config) (providers.LLMProvider, error) {
+	if spec, _ := cmd.Flags().GetString("provider"); spec != "" {
+		return providers.NewProviderFromFlag(spec)
+	}
+	return providers.NewProviderFromConfig(cfg), nil
+}
+
+// applyProfiles sets the agent's default generation options and named model
+// profiles from the config, and which models /model may select. Profiles
+// inherit unset fields from agents.defaults.
+func applyProfiles(ag *agent.AgentLoop, cfg config.Config) {
+	defaults := providers.DefaultChatOptions(cfg.Agents.Defaults)
+	ag.SetChatOptions(defaults)
+	profiles := make(map[string]agent.ModelProfile, len(cfg.Agents.Profiles))
+	for name, p := range cfg.Agents.Profiles {
+		profiles[name] = agent.ModelProfile{Model: p.Model, Options: providers.ApplyProfile(defaults, p)}
+	}
+	ag.SetProfiles(profiles, cfg.Agents.ChannelProfiles)
+	if fc := cfg.Providers.Failover; fc != nil {
+		var models []string
+		for _, t := range fc.Chain {
+			if t.Model != "" {
+				models = append(models, t.Model)
+			}
+		}
+		ag.SetSelectableModels(models)
+	}
+}
+
+// workspaceDir returns the configured workspace, defaulting to
+// ~/.picobot/workspace, with a leading "~/" expanded to the home directory.
+func workspaceDir(cfg config.Config) string {
+	ws := cfg.Agents.Defaults.Workspace
+	if ws == "" {
+		ws = "~/.picobot/workspace"
+	}
+	if strings.HasPrefix(ws, "~/") {
+		home, _ := os.UserHomeDir()
+		ws = filepath.Join(home, ws[2:])
+	}
+	return ws
+}
+
+// contextWindow returns a resolver for a model's context window, consulted
+// on every turn since profiles and /model can switch models. The configured
+// contextWindow applies to the default model; other models use the
+// known-model table. Ollama's num_ctx caps the window of any model it serves.
+func contextWindow(cfg config.Config, provider providers.LLMProvider, defaultModel string) func(model string) int {
+	numCtx := ollamaNumCtx(cfg, provider)
+	return func(model string) int {
+		window := providers.ContextWindow(model)
+		if model == defaultModel && cfg.Agents.Defaults.ContextWindow > 0 {
+			window = cfg.Agents.Defaults.ContextWindow
+		}
+		if numCtx > 0 && numCtx < window {
+			window = numCtx
+		}
+		return window
+	}
+}
+
+// ollamaNumCtx returns the num_ctx of the Ollama provider that may serve a
+// turn, either directly or as a link in the failover chain, or 0 if none.
+func ollamaNumCtx(cfg config.Config, provider providers.LLMProvider) int {
+	switch p := provider.(type) {
+	case *providers.OllamaProvider:
+		return p.NumCtx
+	case *providers.FailoverProvider:
+		if cfg.Providers.Ollama == nil || cfg.Providers.Failover == nil {
+			return 0
+		}
+		for _, t := range cfg.Providers.Failover.Chain {
+			if t.Provider == "ollama" {
+				return cfg.Providers.Ollama.NumCtx
+			}
+		}
+	}
+	return 0
+}
+
+// formatCost renders a cost, marking totals that exclude unpriced models.
+func formatCost(t usage.Total) string {
+	s := fmt.Sprintf("%.4f", t.Cost)
+	if t.Unpriced {
+		s += "*"
+	}
+	return s
+}
+
 func main() {
 	rootCmd := NewRootCmd()
 	if err := rootCmd.Execute(); err != nil {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/local/picobot/internal/agent/memory"
 	"github.com/local/picobot/internal/config"
+	"github.com/local/picobot/internal/usage"
 )
 
 func TestMemoryCLI_ReadAppendWriteRecent(t *testing.T) {
@@ -148,3 +151,129 @@ func TestAgentCLI_ModelFlag(t *testing.T) {
 		t.Fatalf("expected stub echo output, got: %q", out)
 	}
 }
+
+func TestUsageCLI_ReportsTotalsAndCost(t *testing.T) {
+	tmp := t.TempDir()
+	os.Setenv("HOME", tmp)
+	if _, _, err := config.Onboard(); err != nil {
+		t.Fatalf("onboard failed: %v", err)
+	}
+	cfgPath, ws, _ := config.ResolveDefaultPaths()
+	cfg, _ := config.LoadConfig()
+	cfg.Pricing = map[string]config.ModelPrice{"gpt-4o": {InputPerMTok: 2, OutputPerMTok: 8}}
+	_ = config.SaveConfig(cfg, cfgPath)
+
+	tr := usage.NewTracker(ws)
+	_ = tr.Record(usage.Record{Channel: "telegram", ChatID: "42", Model: "gpt-4o", PromptTokens: 1000000, CompletionTokens: 1000000})
+	_ = tr.Record(usage.Record{Channel: "heartbeat", ChatID: "system", Model: "gpt-4o", PromptTokens: 500000})
+
+	cmd := NewRootCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"usage", "--by", "chat"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("usage failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"telegram:42", "10.0000", "heartbeat:system", "1.0000", "TOTAL", "11.0000"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestOnboardOllama_ChoosesInstalledModel(t *testing.T) {
+	tmp := t.TempDir()
+	os.Setenv("HOME", tmp)
+	if _, _, err := config.Onboard(); err != nil {
+		t.Fatalf("onboard failed: %v", err)
+	}
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"models":[{"name":"llama3.2:latest"},{"name":"qwen2.5:7b"}]}`))
+	}))
+	defer h.Close()
+
+	cmd := NewRootCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetIn(strings.NewReader("2\n"))
+	cmd.SetArgs([]string{"onboard", "ollama", "--base", h.URL})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("onboard ollama failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "llama3.2:latest") {
+		t.Fatalf("expected model list in output, got %q", buf.String())
+	}
+
+	cfg, _ := config.LoadConfig()
+	if cfg.Providers.Ollama == nil || cfg.Providers.Ollama.APIBase != h.URL {
+		t.Fatalf("expected ollama provider to be saved, got %+v", cfg.Providers.Ollama)
+	}
+	if cfg.Agents.Defaults.Model != "qwen2.5:7b" {
+		t.Fatalf("expected chosen model to be saved, got %q", cfg.Agents.Defaults.Model)
+	}
+}
+
+func TestAgentCLI_ScriptProvider(t *testing.T) {
+	tmp := t.TempDir()
+	os.Setenv("HOME", tmp)
+	if _, _, err := config.Onboard(); err != nil {
+		t.Fatalf("onboard failed: %v", err)
+	}
+	scenario := filepath.Join(tmp, "scenario.json")
+	os.WriteFile(scenario, []byte(`{"rules":[{"match":"note (.+)","steps":[
+	  {"toolCalls":[{"name":"write_memory","arguments":{"target":"today","content":"$1","append":true}}]},
+	  {"content":"Noted."}
+	]}]}`), 0o644)
+
+	cmd := NewRootCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"agent", "--provider", "script:" + scenario, "-m", "note buy oat milk"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("agent failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Noted.") {
+		t.Fatalf("expected scripted reply, got %q", buf.String())
+	}
+
+	_, ws, _ := config.ResolveDefaultPaths()
+	today, _ := memory.NewMemoryStoreWithWorkspace(ws, 100).ReadToday()
+	if !strings.Contains(today, "buy oat milk") {
+		t.Fatalf("expected scripted tool call to write memory, got %q", today)
+	}
+}
+
+func TestTraceCLI_ShowsLatestTurn(t *testing.T) {
+	tmp := t.TempDir()
+	os.Setenv("HOME", tmp)
+	if _, _, err := config.Onboard(); err != nil {
+		t.Fatalf("onboard failed: %v", err)
+	}
+	scenario := filepath.Join(tmp, "scenario.json")
+	os.WriteFile(scenario, []byte(`{"rules":[{"match":"note (.+)","steps":[
+	  {"toolCalls":[{"name":"write_memory","arguments":{"target":"today","content":"$1","append":true}}]},
+	  {"content":"Noted."}
+	]}]}`), 0o644)
+
+	cmd := NewRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"agent", "--provider", "script:" + scenario, "-m", "note buy oat milk"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("agent failed: %v", err)
+	}
+
+	cmd = NewRootCmd()
+	buf := &bytes.Buffer{}
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"trace", "show"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("trace show failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"cli:direct", "inbound", `"note buy oat milk"`, "context", "provider", "tool", "write_memory", "outbound", `"Noted."`} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/chat"
+)
+
+// approvalTimeout is how long a turn waits for the user to answer an approval prompt.
+const approvalTimeout = 5 * time.Minute
+
+// chatApprover implements tools.Approver by asking in the chat the turn came
+// from. The user's reply arrives as an ordinary inbound message; Run hands it
+// to resolve before dispatching, so it reaches the waiting turn instead of
+// queueing behind it.
+type chatApprover struct {
+	hub     *chat.Hub
+	timeout time.Duration
+
+	mu      sync.Mutex
+	active  bool                     // set while Run is reading the hub
+	slots   map[string]*approvalSlot // one outstanding prompt per chat
+	pending map[string]chan bool     // chat key -> waiting turn
+}
+
+// approvalSlot lets one prompt at a time be outstanding in a chat. It is
+// removed from chatApprover.slots once no request holds or waits for it.
+type approvalSlot struct {
+	ch    chan struct{}
+	users int
+}
+
+func newChatApprover(hub *chat.Hub) *chatApprover {
+	return &chatApprover{hub: hub, timeout: approvalTimeout, slots: make(map[string]*approvalSlot), pending: make(map[string]chan bool)}
+}
+
+// setActive records whether replies can be received (Run is running).
+func (c *chatApprover) setActive(active bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active = active
+}
+
+// Approve posts the prompt to the turn's chat and waits for a yes/no reply.
+// Requests for the same chat are asked one at a time. Nobody can answer on
+// system channels or outside Run, so those calls get tools.ErrNoApprover.
+func (c *chatApprover) Approve(ctx context.Context, req tools.ApprovalRequest) (bool, error) {
+	inv, _ := tools.InvocationFrom(ctx)
+	key := inv.Channel + ":" + inv.ChatID
+	c.mu.Lock()
+	if !c.active || inv.Channel == "" || isSystemChannel(inv.Channel) {
+		c.mu.Unlock()
+		log.Printf("approval: refusing %s, no one to ask on %s", req.Summary, key)
+		return false, tools.ErrNoApprover
+	}
+	slot, ok := c.slots[key]
+	if !ok {
+		slot = &approvalSlot{ch: make(chan struct{}, 1)}
+		c.slots[key] = slot
+	}
+	slot.users++
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		if slot.users--; slot.users == 0 {
+			delete(c.slots, key)
+		}
+		c.mu.Unlock()
+	}()
+
+	select {
+	case slot.ch <- struct{}{}:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+	defer func() { <-slot.ch }()
+
+	reply := make(chan bool, 1)
+	c.mu.Lock()
+	c.pending[key] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	c.send(inv, fmt.Sprintf("Approve running `%s`? Reply yes or no.", req.Summary))
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case ok := <-reply:
+		log.Printf("approval: %s on %s -> %v", req.Summary, key, ok)
+		return ok, nil
+	case <-timer.C:
+		c.send(inv, fmt.Sprintf("No answer, so I did not run `%s`.", req.Summary))
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// resolve delivers msg to a turn waiting for approval in the same chat. It
+// reports whether msg was consumed as the answer. A reply that is neither yes
+// nor no declines the request and is then processed as a normal message.
+func (c *chatApprover) resolve(msg chat.Inbound) bool {
+	key := msg.Channel + ":" + msg.ChatID
+	c.mu.Lock()
+	reply, ok := c.pending[key]
+	if ok {
+		delete(c.pending, key)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	answer, understood := parseYesNo(msg.Content)
+	reply <- answer && understood
+	return understood
+}
+
+func (c *chatApprover) send(inv tools.InvocationContext, content string) {
+	select {
+	case c.hub.Out <- chat.Outbound{Channel: inv.Channel, ChatID: inv.ChatID, Content: content}:
+	default:
+		log.Println("Outbound channel full, dropping message")
+	}
+}
+
+// parseYesNo interprets an approval reply.
+func parseYesNo(s string) (yes bool, ok bool) {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(s), ".!")) {
+	case "yes", "y", "ok", "approve", "approved", "go ahead", "do it":
+		return true, true
+	case "no", "n", "nope", "deny", "denied", "cancel", "stop", "don't":
+		return false, true
+	}
+	return false, false
+}
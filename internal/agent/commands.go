@@ -0,0 +1,319 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/usage"
+)
+
+// Command is an in-chat command answered without a model call. It is invoked
+// as "/name args", or by any message matching Pattern (whose first submatch
+// becomes args), e.g. "remember to buy milk".
+type Command struct {
+	Name        string // without the slash
+	Description string
+	Args        string // argument description for /help and command menus, e.g. "<name>"
+	Pattern     *regexp.Regexp
+	// Record keeps the exchange in the session history, for commands whose
+	// effect the model should know about later.
+	Record bool
+	// Handle returns the reply. It runs in the chat's session worker, so it
+	// never overlaps a turn of the same chat.
+	Handle func(ctx context.Context, msg chat.Inbound, args string) string
+}
+
+// CommandRouter matches inbound messages against registered commands.
+type CommandRouter struct {
+	mu    sync.RWMutex
+	cmds  map[string]Command
+	order []string
+}
+
+// NewCommandRouter constructs an empty router.
+func NewCommandRouter() *CommandRouter {
+	return &CommandRouter{cmds: make(map[string]Command)}
+}
+
+// Register adds c, replacing any command with the same name.
+func (r *CommandRouter) Register(c Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cmds[c.Name]; !ok {
+		r.order = append(r.order, c.Name)
+	}
+	r.cmds[c.Name] = c
+}
+
+// Commands describes the registered commands in registration order, for
+// channels' command menus.
+func (r *CommandRouter) Commands() []chat.Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]chat.Command, 0, len(r.order))
+	for _, name := range r.order {
+		c := r.cmds[name]
+		out = append(out, chat.Command{Name: c.Name, Description: c.Description, Args: c.Args})
+	}
+	return out
+}
+
+// Match finds the command for content. "/name@bot args" (Telegram's form in
+// groups) is accepted. Unknown slash commands do not match and go to the model.
+func (r *CommandRouter) Match(content string) (Command, string, bool) {
+	text := strings.TrimSpace(content)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rest, ok := strings.CutPrefix(text, "/"); ok {
+		name, args, _ := strings.Cut(rest, " ")
+		name, _, _ = strings.Cut(name, "@")
+		if c, ok := r.cmds[strings.ToLower(name)]; ok {
+			return c, strings.TrimSpace(args), true
+		}
+		return Command{}, "", false
+	}
+	for _, name := range r.order {
+		c := r.cmds[name]
+		if c.Pattern == nil {
+			continue
+		}
+		if m := c.Pattern.FindStringSubmatch(text); m != nil {
+			args := ""
+			if len(m) > 1 {
+				args = m[1]
+			}
+			return c, args, true
+		}
+	}
+	return Command{}, "", false
+}
+
+// Commands returns the agent's in-chat commands, for channels' command menus.
+func (a *AgentLoop) Commands() []chat.Command {
+	return a.commands.Commands()
+}
+
+// RegisterCommand adds an in-chat command, replacing a built-in of the same name.
+func (a *AgentLoop) RegisterCommand(c Command) {
+	a.commands.Register(c)
+}
+
+// registerBuiltinCommands installs the default commands.
+func (a *AgentLoop) registerBuiltinCommands() {
+	a.commands.Register(Command{
+		Name: "help", Description: "List available commands",
+		Handle: a.cmdHelp,
+	})
+	a.commands.Register(Command{
+		Name: "reset", Description: "Forget this conversation and start fresh",
+		Handle: a.cmdReset,
+	})
+	a.commands.Register(Command{
+		Name: "stop", Description: "Stop the reply in progress",
+		// Run cancels a running turn itself; this only answers when there is none.
+		Handle: func(ctx context.Context, msg chat.Inbound, args string) string { return "Nothing to stop." },
+	})
+	a.commands.Register(Command{
+		Name: "model", Description: "Show or switch the model (or profile) for this chat", Args: "<name|default>",
+		Handle: a.cmdModel,
+	})
+	a.commands.Register(Command{
+		Name: "remember", Description: "Save a note to today's memory", Args: "<note>",
+		// Quick heuristic: if user asks the agent to remember something explicitly,
+		// store it in today's note and reply immediately without calling the LLM.
+		Pattern: rememberRE, Record: true,
+		Handle: a.cmdRemember,
+	})
+	a.commands.Register(Command{
+		Name: "memory", Description: "Show long-term memory and today's notes",
+		Handle: a.cmdMemory,
+	})
+	a.commands.Register(Command{
+		Name: "skills", Description: "List installed skills",
+		Handle: a.cmdSkills,
+	})
+	a.commands.Register(Command{
+		Name: "jobs", Description: "List scheduled jobs for this chat",
+		Handle: a.cmdJobs,
+	})
+	a.commands.Register(Command{
+		Name: "usage", Description: "Show token usage for this chat over the last 30 days",
+		Handle: a.cmdUsage,
+	})
+}
+
+func (a *AgentLoop) cmdHelp(ctx context.Context, msg chat.Inbound, args string) string {
+	var sb strings.Builder
+	sb.WriteString("Commands:\n")
+	for _, c := range a.commands.Commands() {
+		line := "/" + c.Name
+		if c.Args != "" {
+			line += " " + c.Args
+		}
+		sb.WriteString(fmt.Sprintf("%s - %s\n", line, c.Description))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (a *AgentLoop) cmdReset(ctx context.Context, msg chat.Inbound, args string) string {
+	sess := a.sessions.GetOrCreate(msg.Channel + ":" + msg.ChatID)
+	sess.History = nil
+	sess.Summary = ""
+	a.sessions.Save(sess)
+	return "OK, I've started a new conversation. (Long-term memory is kept.)"
+}
+
+func (a *AgentLoop) cmdModel(ctx context.Context, msg chat.Inbound, args string) string {
+	key := msg.Channel + ":" + msg.ChatID
+	switch args {
+	case "":
+		model, _ := a.modelFor(msg)
+		return "This chat uses " + model + ". Switch with /model <name>, or /model default."
+	case "default", "reset":
+		a.setModelOverride(key, "")
+		model, _ := a.modelFor(msg)
+		return "Back to the default model (" + model + ")."
+	}
+	choices := a.modelChoices()
+	if i := sort.SearchStrings(choices, args); i == len(choices) || choices[i] != args {
+		return "Unknown model or profile " + args + ". Choose one of: " + strings.Join(choices, ", ") + "."
+	}
+	a.setModelOverride(key, args)
+	if _, ok := a.profiles[args]; ok {
+		return "This chat now uses the " + args + " profile."
+	}
+	return "This chat now uses " + args + "."
+}
+
+// modelChoices returns, sorted, the names /model accepts: the profiles, the
+// models they and the agent default to, and those added by SetSelectableModels.
+// Anything else could run up costs or break the chat with a bad model name.
+func (a *AgentLoop) modelChoices() []string {
+	seen := map[string]bool{a.model: true}
+	for name, p := range a.profiles {
+		seen[name] = true
+		if p.Model != "" {
+			seen[p.Model] = true
+		}
+	}
+	for _, m := range a.selectableModels {
+		seen[m] = true
+	}
+	choices := make([]string, 0, len(seen))
+	for name := range seen {
+		if name != "" {
+			choices = append(choices, name)
+		}
+	}
+	sort.Strings(choices)
+	return choices
+}
+
+func (a *AgentLoop) cmdRemember(ctx context.Context, msg chat.Inbound, args string) string {
+	if strings.TrimSpace(args) == "" {
+		return "What should I remember? Usage: /remember <note>"
+	}
+	if err := a.memory.AppendToday(args); err != nil {
+		return "Sorry, I couldn't save that: " + err.Error()
+	}
+	return "OK, I've remembered that."
+}
+
+// maxCommandOutput caps long command replies such as /memory.
+const maxCommandOutput = 3500
+
+func (a *AgentLoop) cmdMemory(ctx context.Context, msg chat.Inbound, args string) string {
+	memCtx, err := a.memory.GetMemoryContext()
+	if err != nil {
+		return "Sorry, I couldn't read memory: " + err.Error()
+	}
+	if strings.TrimSpace(memCtx) == "" {
+		return "Memory is empty."
+	}
+	if len(memCtx) > maxCommandOutput {
+		memCtx = truncateUTF8(memCtx, maxCommandOutput) + "\n...(truncated)"
+	}
+	return memCtx
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 character,
+// which chat APIs such as Telegram's reject.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func (a *AgentLoop) cmdSkills(ctx context.Context, msg chat.Inbound, args string) string {
+	loaded, err := a.context.skillsLoader.LoadAll()
+	if err != nil {
+		return "Sorry, I couldn't load skills: " + err.Error()
+	}
+	if len(loaded) == 0 {
+		return "No skills installed."
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d skill(s):\n", len(loaded)))
+	for _, s := range loaded {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", s.Name, s.Description))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (a *AgentLoop) cmdJobs(ctx context.Context, msg chat.Inbound, args string) string {
+	if a.scheduler == nil {
+		return "Scheduled jobs are only available in gateway mode."
+	}
+	var jobs []string
+	for _, j := range a.scheduler.List() {
+		if j.Channel != msg.Channel || j.ChatID != msg.ChatID {
+			continue
+		}
+		line := fmt.Sprintf("- %s (%s): fires in %v", j.Name, j.ID, time.Until(j.FireAt).Round(time.Second))
+		if j.Recurring {
+			line += fmt.Sprintf(", then every %v", j.Interval)
+		}
+		jobs = append(jobs, line)
+	}
+	if len(jobs) == 0 {
+		return "No scheduled jobs for this chat."
+	}
+	sort.Strings(jobs)
+	return fmt.Sprintf("%d job(s):\n%s", len(jobs), strings.Join(jobs, "\n"))
+}
+
+func (a *AgentLoop) cmdUsage(ctx context.Context, msg chat.Inbound, args string) string {
+	if a.usage == nil {
+		return "Usage tracking is off."
+	}
+	records, err := usage.Load(a.workspace, 30)
+	if err != nil {
+		return "Sorry, I couldn't read usage: " + err.Error()
+	}
+	var mine []usage.Record
+	for _, r := range records {
+		if r.Channel == msg.Channel && r.ChatID == msg.ChatID {
+			mine = append(mine, r)
+		}
+	}
+	if len(mine) == 0 {
+		return "No usage recorded for this chat in the last 30 days."
+	}
+	var sb strings.Builder
+	sb.WriteString("Usage for this chat, last 30 days:\n")
+	for _, t := range usage.Summarize(mine, usage.KeyFuncs["model"], nil) {
+		sb.WriteString(fmt.Sprintf("- %s: %d turns, %d prompt + %d completion tokens\n", t.Key, t.Turns, t.PromptTokens, t.CompletionTokens))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/local/picobot/internal/agent/memory"
 	"github.com/local/picobot/internal/agent/skills"
 	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
 )
 
 // ContextBuilder builds messages for the LLM from session history and current message.
@@ -18,6 +20,8 @@ type ContextBuilder struct {
 	ranker       memory.Ranker
 	topK         int
 	skillsLoader *skills.Loader
+	vision       bool
+	budget       int // max prompt tokens; 0 means unlimited
 }
 
 func NewContextBuilder(workspace string, r memory.Ranker, topK int) *ContextBuilder {
@@ -29,76 +33,248 @@ func NewContextBuilder(workspace string, r memory.Ranker, topK int) *ContextBuil
 	}
 }
 
-func (cb *ContextBuilder) BuildMessages(history []string, currentMessage string, channel, chatID string, memoryContext string, memories []memory.MemoryItem) []providers.Message {
-	msgs := make([]providers.Message, 0, len(history)+8)
+// SetVision controls whether inbound images are attached to the current user
+// message. Enable it only for models that accept image input.
+func (cb *ContextBuilder) SetVision(enabled bool) {
+	cb.vision = enabled
+}
+
+// SetBudget sets the maximum estimated prompt size in tokens. When a prompt
+// would exceed it, BuildMessages drops the oldest history first, then the
+// lowest-ranked memories, then cuts skills down to their descriptions.
+// Zero disables budgeting.
+func (cb *ContextBuilder) SetBudget(tokens int) {
+	cb.budget = tokens
+}
+
+// WithBudget returns a copy of cb with its budget set to tokens, for a turn
+// whose model has a different context window. cb itself is not changed.
+func (cb *ContextBuilder) WithBudget(tokens int) *ContextBuilder {
+	c := *cb
+	c.budget = tokens
+	return &c
+}
+
+// promptParts holds the trimmable pieces of a prompt between assembly passes.
+type promptParts struct {
+	preamble  []providers.Message // system prompt, bootstrap files, channel info
+	skills    []skills.Skill
+	skillFull []bool // false once a skill has been cut down to its description
+	memoryCtx string
+	selected  []memory.MemoryItem // ranked, most relevant first
+	summary   string              // rolling summary of history older than p.history
+	history   []session.Message
+	current   providers.Message
+}
+
+// BuildMessages assembles the prompt. summary is the session's rolling summary
+// of conversation older than history; it is placed just before history. media
+// holds image URLs or data URLs that arrived with currentMessage; they are
+// attached as image parts when vision is enabled, otherwise the model is told
+// that images were sent but not shown. ctx is passed on to a
+// memory.ContextRanker.
+func (cb *ContextBuilder) BuildMessages(ctx context.Context, history []session.Message, summary string, currentMessage string, channel, chatID string, memoryContext string, memories []memory.MemoryItem, media []string) []providers.Message {
+	p := promptParts{memoryCtx: memoryContext, summary: summary, history: history}
+
 	// system prompt
-	msgs = append(msgs, providers.Message{Role: "system", Content: "You are Picobot, a helpful assistant."})
+	p.preamble = append(p.preamble, providers.Message{Role: "system", Content: "You are Picobot, a helpful assistant."})
 
 	// Load workspace bootstrap files (SOUL.md, AGENTS.md, USER.md, TOOLS.md)
 	// These define the agent's personality, instructions, and available tools documentation.
 	bootstrapFiles := []string{"SOUL.md", "AGENTS.md", "USER.md", "TOOLS.md"}
 	for _, name := range bootstrapFiles {
-		p := filepath.Join(cb.workspace, name)
-		data, err := os.ReadFile(p)
+		path := filepath.Join(cb.workspace, name)
+		data, err := os.ReadFile(path)
 		if err != nil {
 			continue // file may not exist yet, skip silently
 		}
 		content := strings.TrimSpace(string(data))
 		if content != "" {
-			msgs = append(msgs, providers.Message{Role: "system", Content: fmt.Sprintf("## %s\n\n%s", name, content)})
+			p.preamble = append(p.preamble, providers.Message{Role: "system", Content: fmt.Sprintf("## %s\n\n%s", name, content)})
 		}
 	}
 
 	// Tell the model which channel it is operating in and that tools are always available.
-	msgs = append(msgs, providers.Message{Role: "system", Content: fmt.Sprintf(
+	p.preamble = append(p.preamble, providers.Message{Role: "system", Content: fmt.Sprintf(
 		"You are operating on channel=%q chatID=%q. You have full access to all registered tools regardless of the channel. Always use your tools when the user asks you to perform actions (file operations, shell commands, web fetches, etc.).",
 		channel, chatID)})
 
 	// instruction for memory tool usage
-	msgs = append(msgs, providers.Message{Role: "system", Content: "If you decide something should be remembered, call the tool 'write_memory' with JSON arguments: {\"target\": \"today\"|\"long\", \"content\": \"...\", \"append\": true|false}. Use a tool call rather than plain chat text when writing memory."})
+	p.preamble = append(p.preamble, providers.Message{Role: "system", Content: "If you decide something should be remembered, call the tool 'write_memory' with JSON arguments: {\"target\": \"today\"|\"long\", \"content\": \"...\", \"append\": true|false}. Use a tool call rather than plain chat text when writing memory."})
 
 	// Load and include skills context
 	loadedSkills, err := cb.skillsLoader.LoadAll()
 	if err != nil {
 		log.Printf("error loading skills: %v", err)
 	}
-	if len(loadedSkills) > 0 {
+	p.skills = loadedSkills
+	p.skillFull = make([]bool, len(loadedSkills))
+	for i := range p.skillFull {
+		p.skillFull[i] = true
+	}
+
+	// select top-K memories using ranker if available
+	p.selected = memories
+	if cb.ranker != nil && len(memories) > 0 {
+		if cr, ok := cb.ranker.(memory.ContextRanker); ok {
+			p.selected = cr.RankContext(ctx, currentMessage, memories, cb.topK)
+		} else {
+			p.selected = cb.ranker.Rank(currentMessage, memories, cb.topK)
+		}
+	}
+
+	// current
+	p.current = currentUserMessage(currentMessage, media, cb.vision)
+
+	msgs := p.assemble()
+	if cb.budget > 0 {
+		msgs = p.fit(cb.budget, msgs)
+	}
+	return msgs
+}
+
+// assemble renders the parts into the final message list.
+func (p *promptParts) assemble() []providers.Message {
+	msgs := make([]providers.Message, 0, len(p.preamble)+len(p.history)+4)
+	msgs = append(msgs, p.preamble...)
+
+	if len(p.skills) > 0 {
 		var sb strings.Builder
 		sb.WriteString("Available Skills:\n")
-		for _, skill := range loadedSkills {
-			sb.WriteString(fmt.Sprintf("\n## %s\n%s\n\n%s\n", skill.Name, skill.Description, skill.Content))
+		for i, skill := range p.skills {
+			if p.skillFull[i] {
+				sb.WriteString(fmt.Sprintf("\n## %s\n%s\n\n%s\n", skill.Name, skill.Description, skill.Content))
+			} else {
+				sb.WriteString(fmt.Sprintf("\n## %s\n%s\n(Full instructions omitted to save space; use the read_skill tool to load them.)\n", skill.Name, skill.Description))
+			}
 		}
 		msgs = append(msgs, providers.Message{Role: "system", Content: sb.String()})
 	}
 
 	// include file-based memory context (long-term + today's notes) if present
-	if memoryContext != "" {
-		msgs = append(msgs, providers.Message{Role: "system", Content: "Memory:\n" + memoryContext})
+	if p.memoryCtx != "" {
+		msgs = append(msgs, providers.Message{Role: "system", Content: "Memory:\n" + p.memoryCtx})
 	}
 
-	// select top-K memories using ranker if available
-	selected := memories
-	if cb.ranker != nil && len(memories) > 0 {
-		selected = cb.ranker.Rank(currentMessage, memories, cb.topK)
-	}
-	if len(selected) > 0 {
+	if len(p.selected) > 0 {
 		var sb strings.Builder
 		sb.WriteString("Relevant memories:\n")
-		for _, m := range selected {
+		for _, m := range p.selected {
 			sb.WriteString(fmt.Sprintf("- %s (%s)\n", m.Text, m.Kind))
 		}
 		msgs = append(msgs, providers.Message{Role: "system", Content: sb.String()})
 	}
 
-	// replay history
-	for _, h := range history {
-		// history items are of the form "role: content"
-		if len(h) > 0 {
-			msgs = append(msgs, providers.Message{Role: "user", Content: h})
+	if p.summary != "" {
+		msgs = append(msgs, providers.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + p.summary})
+	}
+
+	msgs = append(msgs, replayHistory(p.history)...)
+
+	msgs = append(msgs, p.current)
+	return msgs
+}
+
+// fit trims the parts until the estimated prompt fits in budget tokens, in
+// priority order: oldest history, the conversation summary, lowest-ranked
+// memories, then the largest skill bodies. It logs what was dropped.
+func (p *promptParts) fit(budget int, msgs []providers.Message) []providers.Message {
+	before := estimateMessagesTokens(msgs)
+	if before <= budget {
+		return msgs
+	}
+	droppedHistory, droppedMemories, cutSkills := 0, 0, 0
+	droppedSummary := false
+	total := before
+	for total > budget {
+		switch {
+		case len(p.history) > 0:
+			p.history = p.history[1:]
+			droppedHistory++
+		case p.summary != "":
+			p.summary = ""
+			droppedSummary = true
+		case len(p.selected) > 0:
+			p.selected = p.selected[:len(p.selected)-1]
+			droppedMemories++
+		default:
+			largest := -1
+			for i, s := range p.skills {
+				if p.skillFull[i] && (largest < 0 || len(s.Content) > len(p.skills[largest].Content)) {
+					largest = i
+				}
+			}
+			if largest < 0 {
+				log.Printf("context: prompt still ~%d tokens after trimming, over budget of %d", total, budget)
+				return msgs
+			}
+			p.skillFull[largest] = false
+			cutSkills++
 		}
+		msgs = p.assemble()
+		total = estimateMessagesTokens(msgs)
 	}
+	log.Printf("context: trimmed prompt from ~%d to ~%d tokens (budget %d): dropped %d history messages, summary=%v, %d memories; cut %d skills to descriptions",
+		before, total, budget, droppedHistory, droppedSummary, droppedMemories, cutSkills)
+	return msgs
+}
 
-	// current
-	msgs = append(msgs, providers.Message{Role: "user", Content: currentMessage})
+// replayHistory converts session history to provider messages with their
+// original roles. Tool calls and results are only replayed in matched pairs:
+// trimming or summarizing can cut a turn in half, and providers reject a tool
+// result without its call (or a call without its result).
+func replayHistory(history []session.Message) []providers.Message {
+	answered := make(map[string]bool)
+	for _, h := range history {
+		if h.Role == "tool" && h.ToolCallID != "" {
+			answered[h.ToolCallID] = true
+		}
+	}
+	called := make(map[string]bool)
+	msgs := make([]providers.Message, 0, len(history))
+	for _, h := range history {
+		switch h.Role {
+		case "tool":
+			if !called[h.ToolCallID] {
+				continue
+			}
+			msgs = append(msgs, providers.Message{Role: "tool", Content: h.Content, ToolCallID: h.ToolCallID})
+		case "assistant":
+			var calls []providers.ToolCall
+			for _, tc := range h.ToolCalls {
+				if answered[tc.ID] {
+					calls = append(calls, tc)
+					called[tc.ID] = true
+				}
+			}
+			if h.Content == "" && len(calls) == 0 {
+				continue
+			}
+			msgs = append(msgs, providers.Message{Role: "assistant", Content: h.Content, ToolCalls: calls})
+		default:
+			if h.Content != "" {
+				msgs = append(msgs, providers.Message{Role: "user", Content: h.Content})
+			}
+		}
+	}
 	return msgs
 }
+
+// currentUserMessage builds the final user message, with image parts if allowed.
+func currentUserMessage(text string, media []string, vision bool) providers.Message {
+	if len(media) == 0 {
+		return providers.Message{Role: "user", Content: text}
+	}
+	if !vision {
+		note := fmt.Sprintf("[The user attached %d image(s), but the current model cannot view images.]", len(media))
+		return providers.Message{Role: "user", Content: strings.TrimSpace(text + "\n\n" + note)}
+	}
+	m := providers.Message{Role: "user", Content: text}
+	if text != "" {
+		m.Parts = append(m.Parts, providers.ContentPart{Type: "text", Text: text})
+	}
+	for _, u := range media {
+		m.Parts = append(m.Parts, providers.ContentPart{Type: "image_url", ImageURL: u})
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/local/picobot/internal/agent/memory"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
+)
+
+func TestBuildMessagesTrimsToBudgetInPriorityOrder(t *testing.T) {
+	ws := t.TempDir()
+	skillDir := filepath.Join(ws, "skills", "big")
+	os.MkdirAll(skillDir, 0o755)
+	body := strings.TrimSpace(strings.Repeat("step by step instructions ", 400)) // ~2500 tokens
+	os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte("---\nname: big\ndescription: does big things\n---\n"+body), 0o644)
+
+	history := make([]session.Message, 10)
+	for i := range history {
+		history[i] = session.Message{Role: "user", Content: strings.Repeat("h", 396) + string(rune('0'+i))} // ~100 tokens each
+	}
+	mems := []memory.MemoryItem{{Kind: "long", Text: "most relevant"}, {Kind: "long", Text: "least relevant"}}
+
+	cb := NewContextBuilder(ws, nil, 5)
+	full := estimateMessagesTokens(cb.BuildMessages(context.Background(), history, "", "hi", "cli", "c", "", mems, nil))
+
+	// Budget that only requires dropping some history.
+	cb.SetBudget(full - 250)
+	msgs := cb.BuildMessages(context.Background(), history, "", "hi", "cli", "c", "", mems, nil)
+	if n := estimateMessagesTokens(msgs); n > full-250 {
+		t.Fatalf("expected prompt within budget, got %d", n)
+	}
+	joined := joinContents(msgs)
+	if strings.Contains(joined, history[0].Content) || !strings.Contains(joined, history[9].Content) {
+		t.Fatalf("expected oldest history dropped first and newest kept")
+	}
+	if !strings.Contains(joined, "least relevant") || !strings.Contains(joined, body) {
+		t.Fatalf("memories and skills should be untouched while history remains")
+	}
+
+	// Budget small enough to force every stage.
+	cb.SetBudget(400)
+	msgs = cb.BuildMessages(context.Background(), history, "", "hi", "cli", "c", "", mems, nil)
+	joined = joinContents(msgs)
+	if strings.Contains(joined, history[9].Content) || strings.Contains(joined, "least relevant") {
+		t.Fatalf("expected all history and low-ranked memories dropped")
+	}
+	if strings.Contains(joined, body) || !strings.Contains(joined, "does big things") {
+		t.Fatalf("expected skill cut down to its description")
+	}
+	if msgs[len(msgs)-1].Content != "hi" {
+		t.Fatalf("current message must always be kept")
+	}
+}
+
+func joinContents(msgs []providers.Message) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		sb.WriteString(m.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
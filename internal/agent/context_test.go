@@ -1,18 +1,21 @@
 package agent
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/local/picobot/internal/agent/memory"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
 )
 
 func TestBuildMessagesIncludesMemories(t *testing.T) {
 	cb := NewContextBuilder(".", memory.NewSimpleRanker(), 5)
-	history := []string{"user: hi"}
+	history := []session.Message{{Role: "user", Content: "hi"}}
 	mems := []memory.MemoryItem{{Kind: "short", Text: "remember this"}, {Kind: "long", Text: "big fact"}}
 	memCtx := "Long-term memory: important fact"
-	msgs := cb.BuildMessages(history, "hello", "telegram", "123", memCtx, mems)
+	msgs := cb.BuildMessages(context.Background(), history, "", "hello", "telegram", "123", memCtx, mems, nil)
 
 	// Expect at least system prompt + some system messages + user history + current
 	if len(msgs) < 4 {
@@ -39,3 +42,56 @@ func TestBuildMessagesIncludesMemories(t *testing.T) {
 		t.Fatalf("expected memory summary to be present in messages: %v", msgs)
 	}
 }
+
+func TestBuildMessagesAttachesMediaForVision(t *testing.T) {
+	cb := NewContextBuilder(".", nil, 5)
+	media := []string{"data:image/png;base64,AAAA"}
+
+	msgs := cb.BuildMessages(context.Background(), nil, "", "what is this?", "telegram", "123", "", nil, media)
+	last := msgs[len(msgs)-1]
+	if len(last.Parts) != 0 || !strings.Contains(last.Content, "cannot view images") {
+		t.Fatalf("expected a text note without vision, got %+v", last)
+	}
+
+	cb.SetVision(true)
+	msgs = cb.BuildMessages(context.Background(), nil, "", "what is this?", "telegram", "123", "", nil, media)
+	last = msgs[len(msgs)-1]
+	if len(last.Parts) != 2 || last.Parts[0].Text != "what is this?" || last.Parts[1].ImageURL != media[0] {
+		t.Fatalf("expected text and image parts, got %+v", last.Parts)
+	}
+}
+
+func TestBuildMessagesReplaysHistoryWithRoles(t *testing.T) {
+	cb := NewContextBuilder(".", nil, 5)
+	history := []session.Message{
+		// result whose call was summarized away: must not be replayed
+		{Role: "tool", Content: "orphan", ToolCallID: "old"},
+		{Role: "user", Content: "what's the weather?"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{
+			{ID: "1", Name: "web", Arguments: map[string]interface{}{"url": "https://wttr.in"}},
+			{ID: "2", Name: "web"}, // never answered (turn aborted)
+		}},
+		{Role: "tool", Content: "sunny", ToolCallID: "1"},
+		{Role: "assistant", Content: "It's sunny."},
+	}
+	msgs := cb.BuildMessages(context.Background(), history, "", "thanks", "telegram", "1", "", nil, nil)
+
+	var replay []providers.Message
+	for _, m := range msgs {
+		if m.Role != "system" {
+			replay = append(replay, m)
+		}
+	}
+	if len(replay) != 5 {
+		t.Fatalf("expected 4 history messages and the current one, got %+v", replay)
+	}
+	if replay[0].Role != "user" || replay[1].Role != "assistant" || replay[2].Role != "tool" || replay[3].Role != "assistant" {
+		t.Fatalf("unexpected roles: %+v", replay)
+	}
+	if len(replay[1].ToolCalls) != 1 || replay[1].ToolCalls[0].ID != "1" || replay[2].ToolCallID != "1" {
+		t.Fatalf("expected only the answered tool call to be replayed: %+v", replay[1:3])
+	}
+	if replay[3].Content != "It's sunny." || replay[4].Content != "thanks" {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+}
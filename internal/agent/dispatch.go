@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/local/picobot/internal/chat"
+)
+
+// defaultMaxConcurrency is how many sessions are processed at once when
+// SetMaxConcurrency is not called.
+const defaultMaxConcurrency = 4
+
+// dispatcher runs inbound messages with per-session ordering: messages with
+// the same key are handled one at a time in arrival order, while different
+// keys run in parallel on at most max workers.
+type dispatcher struct {
+	handle func(chat.Inbound)
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// queues holds pending messages per key. A key is present while a worker
+	// owns that session, so at most one worker runs per key.
+	queues map[string][]chat.Inbound
+}
+
+func newDispatcher(max int, handle func(chat.Inbound)) *dispatcher {
+	if max <= 0 {
+		max = defaultMaxConcurrency
+	}
+	return &dispatcher{handle: handle, sem: make(chan struct{}, max), queues: make(map[string][]chat.Inbound)}
+}
+
+// dispatch queues msg behind any pending messages for key, starting a worker
+// for the key if none is running. It never blocks on the handler.
+func (d *dispatcher) dispatch(key string, msg chat.Inbound) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if q, busy := d.queues[key]; busy {
+		d.queues[key] = append(q, msg)
+		return
+	}
+	d.queues[key] = nil
+	d.wg.Add(1)
+	go d.work(key, msg)
+}
+
+// work handles msg and then drains the key's queue, waiting for a
+// concurrency slot before each message.
+func (d *dispatcher) work(key string, msg chat.Inbound) {
+	defer d.wg.Done()
+	for {
+		d.sem <- struct{}{}
+		d.handle(msg)
+		<-d.sem
+
+		d.mu.Lock()
+		q := d.queues[key]
+		if len(q) == 0 {
+			delete(d.queues, key)
+			d.mu.Unlock()
+			return
+		}
+		msg = q[0]
+		d.queues[key] = q[1:]
+		d.mu.Unlock()
+	}
+}
+
+// wait blocks until every dispatched message has been handled.
+func (d *dispatcher) wait() {
+	d.wg.Wait()
+}
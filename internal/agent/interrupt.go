@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/local/picobot/internal/chat"
+)
+
+// errTurnStopped is the cancellation cause of a turn interrupted by the user.
+var errTurnStopped = errors.New("turn stopped by user")
+
+const stoppedReply = "Stopped."
+
+// turnTracker holds the cancel function of each session's running turn, so
+// that a later message can interrupt it.
+type turnTracker struct {
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+}
+
+func newTurnTracker() *turnTracker {
+	return &turnTracker{running: make(map[string]context.CancelCauseFunc)}
+}
+
+// start registers a turn for key and returns its context. done must be called
+// when the turn ends.
+func (t *turnTracker) start(ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t.mu.Lock()
+	t.running[key] = cancel
+	t.mu.Unlock()
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.running, key)
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// stop cancels the running turn for key, reporting whether there was one.
+func (t *turnTracker) stop(key string) bool {
+	t.mu.Lock()
+	cancel, ok := t.running[key]
+	t.mu.Unlock()
+	if ok {
+		cancel(errTurnStopped)
+	}
+	return ok
+}
+
+// turnStopped reports whether ctx belongs to a turn the user interrupted.
+func turnStopped(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errTurnStopped)
+}
+
+// SetInterruptOnNewMessage makes a new message in a chat cancel that chat's
+// running turn, as /stop does, before it is processed. Commands never interrupt.
+func (a *AgentLoop) SetInterruptOnNewMessage(enabled bool) {
+	a.interruptOnNew = enabled
+}
+
+// interrupt is called by Run for every inbound message before it is queued
+// behind its session's running turn. It reports whether msg was consumed.
+func (a *AgentLoop) interrupt(msg chat.Inbound) bool {
+	key := msg.Channel + ":" + msg.ChatID
+	c, _, isCommand := a.commands.Match(msg.Content)
+	if isCommand && c.Name == "stop" {
+		// With no turn running, /stop is queued and answered by its command.
+		return a.active.stop(key)
+	}
+	if a.interruptOnNew && !isCommand && !isSystemChannel(msg.Channel) {
+		a.active.stop(key)
+	}
+	return false
+}
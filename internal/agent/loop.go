@@ -2,10 +2,16 @@ package agent
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/local/picobot/internal/agent/memory"
@@ -14,6 +20,8 @@ import (
 	"github.com/local/picobot/internal/cron"
 	"github.com/local/picobot/internal/providers"
 	"github.com/local/picobot/internal/session"
+	"github.com/local/picobot/internal/trace"
+	"github.com/local/picobot/internal/usage"
 )
 
 var rememberRE = regexp.MustCompile(`(?i)^remember(?:\s+to)?\s+(.+)$`)
@@ -43,7 +51,229 @@ type AgentLoop struct {
 	memory        *memory.MemoryStore
 	model         string
 	maxIterations int
-	running       bool
+	streaming     bool
+	usage         *usage.Tracker
+	tracer        *trace.Tracer
+	reasoning     *reasoningLog
+	approvals     *chatApprover
+	commands      *CommandRouter
+	scheduler     *cron.Scheduler
+	workspace     string
+	windowFor     func(model string) int // context window per model; nil means unlimited
+	reserve       int                    // tokens kept free for the reply
+	// maxConcurrency caps how many sessions Run processes at once.
+	maxConcurrency int
+	turns          atomic.Uint64
+	turnPrefix     string
+	active         *turnTracker
+	interruptOnNew bool
+
+	options          providers.ChatOptions // used when no profile applies
+	profiles         map[string]ModelProfile
+	channelProfiles  map[string]string
+	selectableModels []string // extra models /model accepts
+
+	// modelOverrides holds per-chat /model choices, keyed by channel:chatID.
+	overridesMu    sync.Mutex
+	modelOverrides map[string]string
+}
+
+// ModelProfile is a model and generation options selected for a turn. An empty
+// Model uses the agent's default model.
+type ModelProfile struct {
+	Model   string
+	Options providers.ChatOptions
+}
+
+// streamFlushInterval limits how often partial replies are pushed to the hub,
+// so channels that edit messages in place stay within their API rate limits.
+const streamFlushInterval = 750 * time.Millisecond
+
+// streamResetPreview replaces a partial reply that the provider abandoned.
+const streamResetPreview = "…"
+
+// SetStreaming enables forwarding partial replies to the hub while the model is
+// generating. It only takes effect when the provider implements
+// providers.StreamingProvider.
+func (a *AgentLoop) SetStreaming(enabled bool) {
+	a.streaming = enabled
+}
+
+// SetVision enables passing inbound images to the model. Only enable it for
+// vision-capable models; otherwise the model is told an image was not shown.
+func (a *AgentLoop) SetVision(enabled bool) {
+	a.context.SetVision(enabled)
+}
+
+// SetContextWindow budgets prompts to fit the context window (in tokens) of
+// the model serving each turn, as reported by windowFor. reserve is kept free
+// for the reply and is capped at a quarter of the window; the tool
+// definitions' size is subtracted as well. A window <= 0 disables budgeting.
+func (a *AgentLoop) SetContextWindow(windowFor func(model string) int, reserve int) {
+	a.windowFor = windowFor
+	a.reserve = reserve
+}
+
+// contextFor returns the context builder budgeted for model's window.
+func (a *AgentLoop) contextFor(model string) *ContextBuilder {
+	if a.windowFor == nil {
+		return a.context
+	}
+	window := a.windowFor(model)
+	if window <= 0 {
+		return a.context.WithBudget(0)
+	}
+	reserve := a.reserve
+	if reserve <= 0 || reserve > window/4 {
+		reserve = window / 4
+	}
+	budget := window - reserve - estimateToolsTokens(a.tools.Definitions())
+	if budget < window/4 {
+		budget = window / 4
+	}
+	return a.context.WithBudget(budget)
+}
+
+// nextTurnID returns a unique ID for a new turn. The prefix, taken from the
+// start time, keeps IDs from repeating across restarts in the trace log.
+func (a *AgentLoop) nextTurnID() string {
+	return fmt.Sprintf("turn-%s-%d", a.turnPrefix, a.turns.Add(1))
+}
+
+// SetMaxConcurrency caps how many sessions Run processes at once. Messages in
+// the same session are always handled one at a time. n <= 0 uses the default.
+func (a *AgentLoop) SetMaxConcurrency(n int) {
+	if n <= 0 {
+		n = defaultMaxConcurrency
+	}
+	a.maxConcurrency = n
+}
+
+// SetApprovalPolicy makes tool calls matching policy wait for the user to
+// approve them in chat. The turn is aborted if the user declines. A nil policy
+// disables approval.
+func (a *AgentLoop) SetApprovalPolicy(policy *tools.ApprovalPolicy) {
+	a.tools.SetApproval(policy, a.approvals)
+}
+
+// SetChatOptions sets the generation options used when no profile applies.
+func (a *AgentLoop) SetChatOptions(opts providers.ChatOptions) {
+	a.options = opts
+}
+
+// SetProfiles registers named model profiles and the channel to profile
+// mapping. A message can also name a profile in its "profile" metadata (cron
+// jobs do), which takes precedence over its channel's profile.
+func (a *AgentLoop) SetProfiles(profiles map[string]ModelProfile, channelProfiles map[string]string) {
+	a.profiles = profiles
+	a.channelProfiles = channelProfiles
+}
+
+// SetSelectableModels adds models that users may switch a chat to with /model,
+// besides the default model, the profiles and the profiles' models.
+func (a *AgentLoop) SetSelectableModels(models []string) {
+	a.selectableModels = models
+}
+
+// profileFor resolves the model and generation options for a message.
+func (a *AgentLoop) profileFor(channel string, metadata map[string]interface{}) (string, providers.ChatOptions) {
+	name, _ := metadata["profile"].(string)
+	if name == "" {
+		name = a.channelProfiles[channel]
+	}
+	if name == "" {
+		return a.model, a.options
+	}
+	p, ok := a.profiles[name]
+	if !ok {
+		log.Printf("agent: unknown model profile %q for channel %s, using defaults", name, channel)
+		return a.model, a.options
+	}
+	if p.Model == "" {
+		return a.model, p.Options
+	}
+	return p.Model, p.Options
+}
+
+// modelFor resolves the model and generation options for a message, applying
+// the chat's /model override, if any. An override naming a profile selects it;
+// anything else is taken as a model name with the default options.
+func (a *AgentLoop) modelFor(msg chat.Inbound) (string, providers.ChatOptions) {
+	model, opts := a.profileFor(msg.Channel, msg.Metadata)
+	a.overridesMu.Lock()
+	name := a.modelOverrides[msg.Channel+":"+msg.ChatID]
+	a.overridesMu.Unlock()
+	if name == "" {
+		return model, opts
+	}
+	if p, ok := a.profiles[name]; ok {
+		if p.Model == "" {
+			return a.model, p.Options
+		}
+		return p.Model, p.Options
+	}
+	return name, a.options
+}
+
+// setModelOverride sets the model or profile for a chat; "" clears it.
+func (a *AgentLoop) setModelOverride(key, name string) {
+	a.overridesMu.Lock()
+	defer a.overridesMu.Unlock()
+	if name == "" {
+		delete(a.modelOverrides, key)
+		return
+	}
+	a.modelOverrides[key] = name
+}
+
+// SetUsageTracker enables per-turn token usage recording. A nil tracker disables it.
+func (a *AgentLoop) SetUsageTracker(t *usage.Tracker) {
+	a.usage = t
+}
+
+// usageByModel accumulates token usage per model that served the calls.
+type usageByModel map[string]providers.Usage
+
+// add counts resp's usage against the model that served it: resp.Model when
+// set (a failover may have answered with another model), else requested.
+func (u usageByModel) add(requested string, resp providers.LLMResponse) {
+	model := resp.Model
+	if model == "" {
+		model = requested
+	}
+	total := u[model]
+	total.Add(resp.Usage)
+	u[model] = total
+}
+
+// recordUsage writes one turn's accumulated token usage, one record per model,
+// if tracking is enabled and the provider reported any.
+func (a *AgentLoop) recordUsage(channel, chatID, turnID string, used usageByModel) {
+	if a.usage == nil {
+		return
+	}
+	models := make([]string, 0, len(used))
+	for m := range used {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+	for _, m := range models {
+		u := used[m]
+		if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+			continue
+		}
+		err := a.usage.Record(usage.Record{
+			Channel:          channel,
+			ChatID:           chatID,
+			TurnID:           turnID,
+			Model:            m,
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+		})
+		if err != nil {
+			log.Printf("usage: failed to record: %v", err)
+		}
+	}
 }
 
 // NewAgentLoop creates a new AgentLoop with the given provider.
@@ -72,13 +302,13 @@ func NewAgentLoop(b *chat.Hub, provider providers.LLMProvider, model string, max
 
 	reg.Register(tools.NewExecTool(60))
 	reg.Register(tools.NewWebTool())
-	reg.Register(tools.NewSpawnTool())
 	if scheduler != nil {
 		reg.Register(tools.NewCronTool(scheduler))
 	}
 
 	sm := session.NewSessionManager(workspace)
-	ctx := NewContextBuilder(workspace, memory.NewLLMRanker(provider, model), 5)
+	ranker := memory.NewLLMRanker(provider, model)
+	ctx := NewContextBuilder(workspace, ranker, 5)
 	mem := memory.NewMemoryStoreWithWorkspace(workspace, 100)
 	// register memory tool (needs store instance)
 	reg.Register(tools.NewWriteMemoryTool(mem))
@@ -90,195 +320,378 @@ func NewAgentLoop(b *chat.Hub, provider providers.LLMProvider, model string, max
 	reg.Register(tools.NewReadSkillTool(skillMgr))
 	reg.Register(tools.NewDeleteSkillTool(skillMgr))
 
-	return &AgentLoop{hub: b, provider: provider, tools: reg, sessions: sm, context: ctx, memory: mem, model: model, maxIterations: maxIterations}
+	a := &AgentLoop{hub: b, provider: provider, tools: reg, sessions: sm, context: ctx, memory: mem, model: model, maxIterations: maxIterations, maxConcurrency: defaultMaxConcurrency, approvals: newChatApprover(b),
+		commands: NewCommandRouter(), scheduler: scheduler, workspace: workspace, modelOverrides: make(map[string]string), active: newTurnTracker(),
+		turnPrefix: strconv.FormatInt(time.Now().Unix(), 36)}
+	a.registerBuiltinCommands()
+	// Ranking runs while a turn's prompt is built; its usage is charged to
+	// that turn's chat.
+	ranker.SetUsageFunc(func(ctx context.Context, m string, u providers.Usage) {
+		inv, _ := tools.InvocationFrom(ctx)
+		a.recordUsage(inv.Channel, inv.ChatID, inv.TurnID, usageByModel{m: u})
+	})
+	// the spawn tool runs subagents through this loop's provider and tools
+	reg.Register(tools.NewSpawnTool(b, a.runSubagent))
+	return a
 }
 
 // Run starts processing inbound messages. This is a blocking call until context is canceled.
+// Messages for the same channel:chatID are processed in order; different
+// sessions run in parallel, up to the limit set by SetMaxConcurrency.
 func (a *AgentLoop) Run(ctx context.Context) {
-	a.running = true
 	log.Println("Agent loop started")
+	a.approvals.setActive(true)
+	defer a.approvals.setActive(false)
+	d := newDispatcher(a.maxConcurrency, func(msg chat.Inbound) {
+		if ctx.Err() != nil {
+			return // shutting down: drop queued messages
+		}
+		a.processMessage(ctx, msg)
+	})
+	defer d.wait()
 
-	for a.running {
+	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Agent loop received shutdown signal")
-			a.running = false
 			return
 		case msg, ok := <-a.hub.In:
 			if !ok {
 				log.Println("Inbound channel closed, stopping agent loop")
-				a.running = false
 				return
 			}
-
-			log.Printf("Processing message from %s:%s\n", msg.Channel, msg.SenderID)
-
-			// Quick heuristic: if user asks the agent to remember something explicitly,
-			// store it in today's note and reply immediately without calling the LLM.
-			trimmed := strings.TrimSpace(msg.Content)
-			rememberRe := rememberRE
-			if matches := rememberRe.FindStringSubmatch(trimmed); len(matches) == 2 {
-				note := matches[1]
-				if err := a.memory.AppendToday(note); err != nil {
-					log.Printf("error appending to memory: %v", err)
-				}
-				out := chat.Outbound{Channel: msg.Channel, ChatID: msg.ChatID, Content: "OK, I've remembered that."}
-				select {
-				case a.hub.Out <- out:
-				default:
-					log.Println("Outbound channel full, dropping message")
-				}
-				// Only save session for interactive channels, not system triggers.
-				if !isSystemChannel(msg.Channel) {
-					sess := a.sessions.GetOrCreate(msg.Channel + ":" + msg.ChatID)
-					sess.AddMessage("user", msg.Content)
-					sess.AddMessage("assistant", "OK, I've remembered that.")
-					a.sessions.Save(sess)
-				}
+			// An answer to a pending approval goes to the waiting turn, which
+			// still holds its session's worker.
+			if a.approvals.resolve(msg) {
+				continue
+			}
+			// /stop must reach the running turn, not wait behind it.
+			if a.interrupt(msg) {
 				continue
 			}
+			d.dispatch(msg.Channel+":"+msg.ChatID, msg)
+		}
+	}
+}
 
-			// Set tool context (so message tool knows channel+chat)
-			if mt := a.tools.Get("message"); mt != nil {
-				if mtool, ok := mt.(interface{ SetContext(string, string) }); ok {
-					mtool.SetContext(msg.Channel, msg.ChatID)
-				}
+// processMessage runs one inbound message through the model and tools and
+// sends the reply. It is called concurrently for different sessions.
+func (a *AgentLoop) processMessage(ctx context.Context, msg chat.Inbound) {
+	log.Printf("Processing message from %s:%s\n", msg.Channel, msg.SenderID)
+
+	// In-chat commands (/reset, /model, "remember ...") are answered
+	// directly, without calling the LLM.
+	if c, args, ok := a.commands.Match(msg.Content); ok {
+		reply := c.Handle(ctx, msg, args)
+		out := chat.Outbound{Channel: msg.Channel, ChatID: msg.ChatID, Content: reply}
+		select {
+		case a.hub.Out <- out:
+		default:
+			log.Println("Outbound channel full, dropping message")
+		}
+		// Only save session for interactive channels, not system triggers.
+		if c.Record && !isSystemChannel(msg.Channel) {
+			sess := a.sessions.GetOrCreate(msg.Channel + ":" + msg.ChatID)
+			sess.AddMessage("user", msg.Content)
+			sess.AddMessage("assistant", reply)
+			a.saveSession(ctx, msg.Channel, msg.ChatID, sess)
+		}
+		return
+	}
+
+	// Tools that act on the current chat (message, cron) read it from ctx.
+	turnID := a.nextTurnID()
+	log.Printf("%s started for %s:%s", turnID, msg.Channel, msg.ChatID)
+	ctx = tools.WithInvocation(ctx, tools.InvocationContext{
+		Channel:    msg.Channel,
+		ChatID:     msg.ChatID,
+		SenderID:   msg.SenderID,
+		SessionKey: msg.Channel + ":" + msg.ChatID,
+		TurnID:     turnID,
+	})
+	a.trace(ctx, trace.Event{Kind: trace.KindInbound, Text: trace.Truncate(msg.Content)})
+	// turnCtx is canceled by /stop; ctx stays valid for the bookkeeping after.
+	turnCtx, done := a.active.start(ctx, msg.Channel+":"+msg.ChatID)
+
+	// Build messages from session, long-term memory, and recent memory.
+	// System channels (heartbeat, cron) get a blank ephemeral session so
+	// their history never accumulates and bloats the context window.
+	var sess *session.Session
+	if isSystemChannel(msg.Channel) {
+		sess = &session.Session{Key: msg.Channel + ":" + msg.ChatID}
+	} else {
+		sess = a.sessions.GetOrCreate(msg.Channel + ":" + msg.ChatID)
+	}
+	// get file-backed memory context (long-term + today)
+	memCtx, _ := a.memory.GetMemoryContext()
+	memories := a.memory.Recent(5)
+	model, opts := a.modelFor(msg)
+	cb := a.contextFor(model)
+	messages := cb.BuildMessages(turnCtx, sess.GetHistory(), sess.Summary, msg.Content, msg.Channel, msg.ChatID, memCtx, memories, msg.Media)
+	promptLen := len(messages) // messages past this are the turn's tool calls and results
+	a.traceContext(ctx, messages)
+
+	iteration := 0
+	finalContent := ""
+	answered, looping := false, false
+	guard := newLoopGuard(a.tools.IsParallelSafe) // parallel-safe calls only read
+	turnUsage := usageByModel{}
+	toolDefs := a.tools.Definitions()
+	for iteration < a.maxIterations {
+		iteration++
+		start := time.Now()
+		resp, err := a.chat(turnCtx, messages, toolDefs, msg, model, opts)
+		a.traceProvider(ctx, model, start, resp, err)
+		turnUsage.add(model, resp)
+		a.recordReasoning(msg.Channel, msg.ChatID, model, resp.Reasoning)
+		if err != nil {
+			if turnStopped(turnCtx) {
+				finalContent = stoppedReply
+				break
 			}
-			if ct := a.tools.Get("cron"); ct != nil {
-				if ctool, ok := ct.(interface{ SetContext(string, string) }); ok {
-					ctool.SetContext(msg.Channel, msg.ChatID)
-				}
+			// A prompt that overflows the context window usually does so because of
+			// accumulated history; retry the first call once without it.
+			if errors.Is(err, providers.ErrContextLength) && iteration == 1 && len(sess.GetHistory()) > 0 {
+				log.Printf("provider error: %v; retrying without session history", err)
+				messages = cb.BuildMessages(turnCtx, nil, sess.Summary, msg.Content, msg.Channel, msg.ChatID, memCtx, memories, msg.Media)
+				promptLen = len(messages)
+				a.traceContext(ctx, messages)
+				continue
 			}
+			log.Printf("provider error: %v", err)
+			finalContent = providerErrorReply(err)
+			break
+		}
 
-			// Build messages from session, long-term memory, and recent memory.
-			// System channels (heartbeat, cron) get a blank ephemeral session so
-			// their history never accumulates and bloats the context window.
-			var sess *session.Session
-			if isSystemChannel(msg.Channel) {
-				sess = &session.Session{Key: msg.Channel + ":" + msg.ChatID}
-			} else {
-				sess = a.sessions.GetOrCreate(msg.Channel + ":" + msg.ChatID)
+		if resp.HasToolCalls {
+			// append assistant message with tool_calls attached
+			messages = append(messages, providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+			var run []providers.ToolCall
+			if run, looping = guard.check(resp.ToolCalls); looping {
+				log.Printf("%s: model keeps repeating tool calls, stopping", turnID)
+				break
 			}
-			// get file-backed memory context (long-term + today)
-			memCtx, _ := a.memory.GetMemoryContext()
-			memories := a.memory.Recent(5)
-			messages := a.context.BuildMessages(sess.GetHistory(), msg.Content, msg.Channel, msg.ChatID, memCtx, memories)
-
-			iteration := 0
-			finalContent := ""
-			lastToolResult := ""
-			toolDefs := a.tools.Definitions()
-			for iteration < a.maxIterations {
-				iteration++
-				resp, err := a.provider.Chat(ctx, messages, toolDefs, a.model)
-				if err != nil {
-					log.Printf("provider error: %v", err)
-					finalContent = "Sorry, I encountered an error while processing your request."
+			// Execute each tool call and return results with "tool" role
+			var denied error
+			for i, r := range a.runTools(turnCtx, a.tools, run) {
+				tc := run[i]
+				if errors.Is(r.err, tools.ErrNotApproved) {
+					denied = r.err
+					messages = append(messages, providers.Message{Role: "tool", Content: "(tool error) " + r.err.Error(), ToolCallID: tc.ID})
 					break
 				}
-
-				if resp.HasToolCalls {
-					// append assistant message with tool_calls attached
-					messages = append(messages, providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
-					// Execute each tool call and return results with "tool" role
-					for _, tc := range resp.ToolCalls {
-						res, err := a.tools.Execute(ctx, tc.Name, tc.Arguments)
-						if err != nil {
-							res = "(tool error) " + err.Error()
-						}
-						lastToolResult = res
-						messages = append(messages, providers.Message{Role: "tool", Content: res, ToolCallID: tc.ID})
-					}
-					// loop again
-					continue
-				} else {
-					finalContent = resp.Content
-					break
+				res := r.content
+				if r.err != nil {
+					res = "(tool error) " + r.err.Error()
 				}
+				messages = append(messages, providers.Message{Role: "tool", Content: res, ToolCallID: tc.ID})
 			}
-
-			if finalContent == "" && lastToolResult != "" {
-				finalContent = lastToolResult
-			} else if finalContent == "" {
-				finalContent = "I've completed processing but have no response to give."
+			messages = guard.answer(messages)
+			if denied != nil {
+				// the user said no: abort the turn rather than let the model try another way
+				finalContent = notApprovedReply(denied)
+				break
 			}
-
-			// Save session for interactive channels only.
-			// System channels (heartbeat, cron) are stateless triggers — their
-			// history must not be persisted, otherwise the file grows unboundedly.
-			if !isSystemChannel(msg.Channel) {
-				sess.AddMessage("user", msg.Content)
-				sess.AddMessage("assistant", finalContent)
-				a.sessions.Save(sess)
+			if turnStopped(turnCtx) {
+				finalContent = stoppedReply
+				break
 			}
+			// loop again
+			continue
+		} else {
+			finalContent = resp.Content
+			answered = true
+			break
+		}
+	}
+	if !answered && finalContent == "" {
+		// out of iterations, or stopped by the loop guard
+		resp, err := a.wrapUp(turnCtx, messages, model, opts, looping)
+		turnUsage.add(model, resp)
+		switch {
+		case turnStopped(turnCtx):
+			finalContent = stoppedReply
+		case err != nil:
+			finalContent = wrapUpFailedReply
+		default:
+			finalContent = resp.Content
+		}
+	}
+	// Past this point the turn can no longer be stopped; a later /stop gets
+	// "Nothing to stop." rather than being swallowed.
+	done()
+
+	a.recordUsage(msg.Channel, msg.ChatID, turnID, turnUsage)
 
-			out := chat.Outbound{Channel: msg.Channel, ChatID: msg.ChatID, Content: finalContent}
-			select {
-			case a.hub.Out <- out:
-			default:
-				log.Println("Outbound channel full, dropping message")
+	if finalContent == "" {
+		finalContent = "I've completed processing but have no response to give."
+	}
+
+	// Save session for interactive channels only.
+	// System channels (heartbeat, cron) are stateless triggers — their
+	// history must not be persisted, otherwise the file grows unboundedly.
+	if !isSystemChannel(msg.Channel) {
+		sess.Add(session.Message{Role: "user", Content: msg.Content, SenderID: msg.SenderID, Metadata: msg.Metadata})
+		for _, m := range messages[promptLen:] {
+			if m.Role == "system" {
+				continue // loop guard corrections only matter within the turn
 			}
-		default:
-			// idle tick
-			time.Sleep(100 * time.Millisecond)
+			sess.Add(sessionMessage(m))
 		}
+		sess.AddMessage("assistant", finalContent)
+	}
+
+	out := chat.Outbound{Channel: msg.Channel, ChatID: msg.ChatID, Content: finalContent, StreamID: turnID}
+	select {
+	case a.hub.Out <- out:
+	default:
+		log.Println("Outbound channel full, dropping message")
+	}
+	a.trace(ctx, trace.Event{Kind: trace.KindOutbound, Text: trace.Truncate(finalContent)})
+
+	// Summarize and save after replying so the user doesn't wait for it; the
+	// session's next message is held until this returns.
+	if !isSystemChannel(msg.Channel) {
+		a.saveSession(ctx, msg.Channel, msg.ChatID, sess)
 	}
 }
 
 // ProcessDirect sends a message directly to the provider and returns the response.
 // It supports tool calling - if the model requests tools, they will be executed.
-func (a *AgentLoop) ProcessDirect(content string, timeout time.Duration) (string, error) {
+func (a *AgentLoop) ProcessDirect(content string, timeout time.Duration) (reply string, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	// Set tool context so message/cron tools know the originating channel,
-	// matching what Run() does for hub-based messages.
-	if mt := a.tools.Get("message"); mt != nil {
-		if mtool, ok := mt.(interface{ SetContext(string, string) }); ok {
-			mtool.SetContext("cli", "direct")
-		}
-	}
-	if ct := a.tools.Get("cron"); ct != nil {
-		if ctool, ok := ct.(interface{ SetContext(string, string) }); ok {
-			ctool.SetContext("cli", "direct")
+	// Give message/cron tools the originating chat, matching what
+	// processMessage does for hub-based messages.
+	turnID := a.nextTurnID()
+	ctx = tools.WithInvocation(ctx, tools.InvocationContext{Channel: "cli", ChatID: "direct", SessionKey: "cli:direct", TurnID: turnID})
+	a.trace(ctx, trace.Event{Kind: trace.KindInbound, Text: trace.Truncate(content)})
+	defer func() {
+		e := trace.Event{Kind: trace.KindOutbound, Text: trace.Truncate(reply)}
+		if err != nil {
+			e.Error = err.Error()
 		}
-	}
+		a.trace(ctx, e)
+	}()
 
 	// Build full context (bootstrap files, skills, memory) just like the main loop
 	memCtx, _ := a.memory.GetMemoryContext()
 	memories := a.memory.Recent(5)
-	messages := a.context.BuildMessages(nil, content, "cli", "direct", memCtx, memories)
+	model, opts := a.profileFor("cli", nil)
+	messages := a.contextFor(model).BuildMessages(ctx, nil, "", content, "cli", "direct", memCtx, memories, nil)
+	a.traceContext(ctx, messages)
 
 	// Support tool calling iterations (similar to main loop)
-	var lastToolResult string
+	turnUsage := usageByModel{}
+	defer func() { a.recordUsage("cli", "direct", turnID, turnUsage) }()
+	guard := newLoopGuard(a.tools.IsParallelSafe)
+	looping := false
 	for iteration := 0; iteration < a.maxIterations; iteration++ {
-		resp, err := a.provider.Chat(ctx, messages, a.tools.Definitions(), a.model)
+		start := time.Now()
+		resp, err := a.provider.Chat(ctx, messages, a.tools.Definitions(), model, opts)
+		a.traceProvider(ctx, model, start, resp, err)
 		if err != nil {
 			return "", err
 		}
+		turnUsage.add(model, resp)
+		a.recordReasoning("cli", "direct", model, resp.Reasoning)
 
 		if !resp.HasToolCalls {
-			// No tool calls, return the response (fall back to last tool result if empty)
-			if resp.Content != "" {
-				return resp.Content, nil
-			}
-			if lastToolResult != "" {
-				return lastToolResult, nil
-			}
 			return resp.Content, nil
 		}
 
 		// Execute tool calls
 		messages = append(messages, providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
-		for _, tc := range resp.ToolCalls {
-			result, err := a.tools.Execute(ctx, tc.Name, tc.Arguments)
-			if err != nil {
-				result = "(tool error) " + err.Error()
+		var run []providers.ToolCall
+		if run, looping = guard.check(resp.ToolCalls); looping {
+			break
+		}
+		for i, r := range a.runTools(ctx, a.tools, run) {
+			if errors.Is(r.err, tools.ErrNotApproved) {
+				return notApprovedReply(r.err), nil
 			}
-			lastToolResult = result
-			messages = append(messages, providers.Message{Role: "tool", Content: result, ToolCallID: tc.ID})
+			result := r.content
+			if r.err != nil {
+				result = "(tool error) " + r.err.Error()
+			}
+			messages = append(messages, providers.Message{Role: "tool", Content: result, ToolCallID: run[i].ID})
 		}
+		messages = guard.answer(messages)
+	}
+
+	// out of iterations, or stopped by the loop guard
+	resp, err := a.wrapUp(ctx, messages, model, opts, looping)
+	turnUsage.add(model, resp)
+	if err != nil {
+		return wrapUpFailedReply, nil
+	}
+	return resp.Content, nil
+}
+
+// maxStoredToolResult caps the tool result text kept in session history.
+const maxStoredToolResult = 2000
+
+// sessionMessage converts a tool-calling step of a turn for the session history.
+func sessionMessage(m providers.Message) session.Message {
+	content := m.Content
+	if m.Role == "tool" && len(content) > maxStoredToolResult {
+		content = truncateUTF8(content, maxStoredToolResult) + "\n...(truncated)"
 	}
+	return session.Message{Role: m.Role, Content: content, ToolCalls: m.ToolCalls, ToolCallID: m.ToolCallID}
+}
+
+// notApprovedReply returns the reply for a turn aborted because a tool call
+// was not approved.
+func notApprovedReply(err error) string {
+	return "OK, I stopped there and didn't run it (" + err.Error() + ")."
+}
+
+// providerErrorReply returns the user-facing message for a failed provider call.
+func providerErrorReply(err error) string {
+	switch {
+	case errors.Is(err, providers.ErrRateLimited):
+		return "The model provider is rate limiting me right now. Please try again in a minute."
+	case errors.Is(err, providers.ErrAuth):
+		return "I couldn't authenticate with the model provider. Please check the API key in the config."
+	case errors.Is(err, providers.ErrContextLength):
+		return "This conversation is too long for the model's context window. Try a shorter message or start a new conversation."
+	default:
+		return "Sorry, I encountered an error while processing your request."
+	}
+}
 
-	return "Max iterations reached without final response", nil
+// chat calls the provider for an interactive turn. When streaming is enabled and
+// supported, partial text is forwarded to the originating chat as Partial
+// outbound messages, throttled to streamFlushInterval.
+func (a *AgentLoop) chat(ctx context.Context, messages []providers.Message, toolDefs []providers.ToolDefinition, msg chat.Inbound, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	sp, ok := a.provider.(providers.StreamingProvider)
+	if !a.streaming || !ok || isSystemChannel(msg.Channel) {
+		return a.provider.Chat(ctx, messages, toolDefs, model, opts)
+	}
+
+	inv, _ := tools.InvocationFrom(ctx)
+	var sb strings.Builder
+	var lastFlush time.Time
+	return sp.ChatStream(ctx, messages, toolDefs, model, opts, func(delta string) {
+		var preview string
+		if delta == providers.StreamReset {
+			// the text so far was abandoned; blank it out rather than append to it
+			sb.Reset()
+			preview = streamResetPreview
+		} else {
+			sb.WriteString(delta)
+			preview = sb.String()
+			if time.Since(lastFlush) < streamFlushInterval {
+				return
+			}
+		}
+		lastFlush = time.Now()
+		out := chat.Outbound{Channel: msg.Channel, ChatID: msg.ChatID, Content: preview, Partial: true, StreamID: inv.TurnID}
+		select {
+		case a.hub.Out <- out:
+		default:
+			// partial updates are best-effort; the final reply is always sent
+		}
+	})
 }
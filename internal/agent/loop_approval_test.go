@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/config"
+	"github.com/local/picobot/internal/providers"
+)
+
+func TestAgentWaitsForApprovalInChat(t *testing.T) {
+	for _, tc := range []struct {
+		reply   string
+		runs    bool
+		content string
+	}{
+		{"yes", true, "done"},
+		{"no", false, "didn't run it"},
+	} {
+		t.Run(tc.reply, func(t *testing.T) {
+			b := chat.NewHub(10)
+			ag := NewAgentLoop(b, probeProvider{}, "fake", 3, t.TempDir(), nil)
+			probe := &probeTool{got: make(chan tools.InvocationContext, 1)}
+			ag.tools.Register(probe)
+			policy, err := tools.NewApprovalPolicy([]config.ApprovalRule{{Tool: "probe"}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			ag.SetApprovalPolicy(policy)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			go ag.Run(ctx)
+
+			b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "5", Content: "do the thing"}
+			select {
+			case out := <-b.Out:
+				if out.ChatID != "5" || !strings.Contains(out.Content, "Approve running `probe") {
+					t.Fatalf("expected an approval prompt, got %+v", out)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for approval prompt")
+			}
+
+			// the reply arrives as a normal inbound message for the same chat
+			b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "5", Content: tc.reply}
+			select {
+			case out := <-b.Out:
+				if !strings.Contains(out.Content, tc.content) {
+					t.Fatalf("expected reply containing %q, got %q", tc.content, out.Content)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for final reply")
+			}
+			ran := len(probe.got) == 1
+			if ran != tc.runs {
+				t.Fatalf("expected tool run=%v, got %v", tc.runs, ran)
+			}
+			ag.approvals.mu.Lock()
+			defer ag.approvals.mu.Unlock()
+			if len(ag.approvals.slots) != 0 {
+				t.Fatalf("expected the chat's approval slot released, got %v", ag.approvals.slots)
+			}
+		})
+	}
+}
+
+// resultEchoProvider calls the probe tool once, then replies with its result.
+type resultEchoProvider struct{}
+
+func (resultEchoProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	if last := messages[len(messages)-1]; last.Role == "tool" {
+		return providers.LLMResponse{Content: last.Content}, nil
+	}
+	return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{ID: "1", Name: "probe"}}}, nil
+}
+func (resultEchoProvider) GetDefaultModel() string { return "fake" }
+
+func TestAgentSaysNoOneCouldApproveOnSystemTurns(t *testing.T) {
+	b := chat.NewHub(10)
+	ag := NewAgentLoop(b, resultEchoProvider{}, "fake", 3, t.TempDir(), nil)
+	probe := &probeTool{got: make(chan tools.InvocationContext, 1)}
+	ag.tools.Register(probe)
+	policy, _ := tools.NewApprovalPolicy([]config.ApprovalRule{{Tool: "probe"}})
+	ag.SetApprovalPolicy(policy)
+	ag.approvals.setActive(true)
+
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "cron", ChatID: "job", Content: "run the job"})
+	out := <-b.Out
+	if !strings.Contains(out.Content, "no one can be asked") || strings.Contains(out.Content, "declined") {
+		t.Fatalf("expected the model told no one could approve, got %q", out.Content)
+	}
+	if len(probe.got) != 0 || len(ag.approvals.slots) != 0 {
+		t.Fatal("expected the call not to run and no approval slot left behind")
+	}
+}
+
+func TestParseYesNo(t *testing.T) {
+	for in, want := range map[string][2]bool{
+		"Yes":       {true, true},
+		" y ":       {true, true},
+		"no.":       {false, true},
+		"maybe":     {false, false},
+		"yes but..": {false, false},
+	} {
+		yes, ok := parseYesNo(in)
+		if yes != want[0] || ok != want[1] {
+			t.Errorf("parseYesNo(%q) = %v, %v; want %v, %v", in, yes, ok, want[0], want[1])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+func TestCommandRouterMatch(t *testing.T) {
+	ag := NewAgentLoop(chat.NewHub(10), &FailingProvider{}, "m", 3, t.TempDir(), nil)
+
+	cases := []struct {
+		in, name, args string
+		ok             bool
+	}{
+		{"/reset", "reset", "", true},
+		{"  /model@picobot gpt-4o ", "model", "gpt-4o", true},
+		{"/MODEL fast", "model", "fast", true},
+		{"remember to water the plants", "remember", "water the plants", true},
+		{"/unknown thing", "", "", false},
+		{"what's the weather?", "", "", false},
+	}
+	for _, tc := range cases {
+		c, args, ok := ag.commands.Match(tc.in)
+		if ok != tc.ok || c.Name != tc.name || args != tc.args {
+			t.Errorf("Match(%q) = %q, %q, %v; want %q, %q, %v", tc.in, c.Name, args, ok, tc.name, tc.args, tc.ok)
+		}
+	}
+}
+
+func TestAgentCommandsSkipTheModel(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &capturingProvider{}
+	ag := NewAgentLoop(b, p, "strong-model", 3, t.TempDir(), nil)
+	ag.SetProfiles(map[string]ModelProfile{"fast": {Model: "fast-model", Options: providers.ChatOptions{MaxTokens: 256}}}, nil)
+	send := func(content string) string {
+		ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", SenderID: "u", Content: content})
+		return (<-b.Out).Content
+	}
+
+	if reply := send("/help"); !strings.Contains(reply, "/model <name|default>") || !strings.Contains(reply, "/reset") {
+		t.Fatalf("unexpected help: %q", reply)
+	}
+	send("hello")
+	if reply := send("/model fast"); !strings.Contains(reply, "fast profile") {
+		t.Fatalf("unexpected /model reply: %q", reply)
+	}
+	send("hello again")
+	if reply := send("/model"); !strings.Contains(reply, "fast-model") {
+		t.Fatalf("expected /model to report the override, got %q", reply)
+	}
+	send("/model default")
+	send("and again")
+
+	if len(p.models) != 3 || p.models[0] != "strong-model" || p.models[1] != "fast-model" || p.opts[1].MaxTokens != 256 || p.models[2] != "strong-model" {
+		t.Fatalf("expected only plain messages to reach the model, with the override applied: %v", p.models)
+	}
+
+	sess := ag.sessions.GetOrCreate("telegram:1")
+	if len(sess.History) == 0 {
+		t.Fatal("expected history before /reset")
+	}
+	for _, m := range sess.History {
+		if strings.HasPrefix(m.Content, "/") {
+			t.Fatalf("commands should not be recorded in history: %+v", m)
+		}
+	}
+	send("/reset")
+	if sess := ag.sessions.GetOrCreate("telegram:1"); len(sess.History) != 0 || sess.Summary != "" {
+		t.Fatalf("expected /reset to clear the session, got %+v", sess)
+	}
+}
+
+func TestModelCommandOnlyAcceptsKnownModels(t *testing.T) {
+	ag := NewAgentLoop(chat.NewHub(10), &capturingProvider{}, "strong-model", 3, t.TempDir(), nil)
+	ag.SetProfiles(map[string]ModelProfile{"fast": {Model: "fast-model"}}, nil)
+	ag.SetSelectableModels([]string{"backup-model"})
+	msg := chat.Inbound{Channel: "telegram", ChatID: "1"}
+
+	reply := ag.cmdModel(context.Background(), msg, "gpt-4-32k")
+	if !strings.Contains(reply, "Unknown") || !strings.Contains(reply, "backup-model, fast, fast-model, strong-model") {
+		t.Fatalf("expected the valid choices, got %q", reply)
+	}
+	if model, _ := ag.modelFor(msg); model != "strong-model" {
+		t.Fatalf("an unknown model must not be applied, got %s", model)
+	}
+	for _, name := range []string{"fast", "fast-model", "backup-model", "strong-model"} {
+		if reply := ag.cmdModel(context.Background(), msg, name); strings.Contains(reply, "Unknown") {
+			t.Fatalf("expected %s to be accepted, got %q", name, reply)
+		}
+	}
+}
+
+func TestTruncateUTF8KeepsWholeRunes(t *testing.T) {
+	s := strings.Repeat("é", 10) // two bytes each
+	if got := truncateUTF8(s, 5); got != "éé" {
+		t.Fatalf("expected a cut before the split rune, got %q", got)
+	}
+	if got := truncateUTF8(s, 100); got != s {
+		t.Fatalf("expected a short string unchanged, got %q", got)
+	}
+	if got := truncateUTF8("日本", 2); got != "" || !utf8.ValidString(got) {
+		t.Fatalf("expected an empty string when no rune fits, got %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// blockingProvider echoes the last user message, holding any call whose
+// content is "slow" until release is closed.
+type blockingProvider struct {
+	release chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (p *blockingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	content := messages[len(messages)-1].Content
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxSeen {
+		p.maxSeen = p.inFlight
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+	if content == "slow" {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return providers.LLMResponse{}, ctx.Err()
+		}
+	}
+	return providers.LLMResponse{Content: content}, nil
+}
+
+func (p *blockingProvider) running() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight
+}
+
+func (p *blockingProvider) GetDefaultModel() string { return "test-model" }
+
+func TestAgentSlowSessionDoesNotBlockOthers(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &blockingProvider{release: make(chan struct{})}
+	ag := NewAgentLoop(b, p, "test-model", 3, t.TempDir(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "slow", Content: "slow"}
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "slow", Content: "after slow"}
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "fast", Content: "fast"}
+
+	select {
+	case out := <-b.Out:
+		if out.ChatID != "fast" || out.Content != "fast" {
+			t.Fatalf("expected the fast chat to reply first, got %s: %q", out.ChatID, out.Content)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("fast chat was blocked by the slow one")
+	}
+
+	close(p.release)
+	for _, want := range []string{"slow", "after slow"} {
+		select {
+		case out := <-b.Out:
+			if out.ChatID != "slow" || out.Content != want {
+				t.Fatalf("expected %q in the slow chat, got %s: %q", want, out.ChatID, out.Content)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", want)
+		}
+	}
+}
+
+func TestAgentMaxConcurrencyLimitsParallelSessions(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &blockingProvider{release: make(chan struct{})}
+	ag := NewAgentLoop(b, p, "test-model", 3, t.TempDir(), nil)
+	ag.SetMaxConcurrency(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "1", Content: "slow"}
+	for p.running() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "2", Content: "hi"}
+
+	select {
+	case out := <-b.Out:
+		t.Fatalf("expected no reply while the only slot is busy, got %s: %q", out.ChatID, out.Content)
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(p.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-b.Out:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for replies")
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.maxSeen != 1 {
+		t.Fatalf("expected at most 1 concurrent call, saw %d", p.maxSeen)
+	}
+}
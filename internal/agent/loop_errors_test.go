@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// failingProvider always returns the configured error.
+type failingProvider struct {
+	err error
+}
+
+func (p *failingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	return providers.LLMResponse{}, p.err
+}
+
+func (p *failingProvider) GetDefaultModel() string { return "failing" }
+
+func TestAgentRepliesPerProviderErrorType(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&providers.APIError{Provider: "OpenAI", StatusCode: 429, Status: "429 Too Many Requests"}, providerErrorReply(providers.ErrRateLimited)},
+		{&providers.APIError{Provider: "OpenAI", StatusCode: 401, Status: "401 Unauthorized"}, providerErrorReply(providers.ErrAuth)},
+		{&providers.APIError{Provider: "OpenAI", StatusCode: 500, Status: "500 Internal Server Error"}, "Sorry, I encountered an error while processing your request."},
+	}
+	for _, tc := range cases {
+		b := chat.NewHub(10)
+		ag := NewAgentLoop(b, &failingProvider{err: tc.err}, "failing", 3, t.TempDir(), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		go ag.Run(ctx)
+		b.In <- chat.Inbound{Channel: "cli", SenderID: "user", ChatID: "one", Content: "hi"}
+
+		select {
+		case out := <-b.Out:
+			if out.Content != tc.want {
+				t.Fatalf("%v: expected %q, got %q", tc.err, tc.want, out.Content)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatalf("%v: timeout waiting for reply", tc.err)
+		}
+		cancel()
+	}
+}
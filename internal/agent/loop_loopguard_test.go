@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// stuckProvider keeps calling the probe tool, with the same arguments unless
+// vary is set, and answers the tool-less wrap-up call with a summary.
+type stuckProvider struct {
+	vary bool
+
+	mu       sync.Mutex
+	calls    int
+	warnings int    // loop guard corrections seen
+	wrapUp   string // system instruction of the wrap-up call
+}
+
+func (p *stuckProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if tools == nil {
+		p.wrapUp = messages[len(messages)-1].Content
+		return providers.LLMResponse{Content: "summary of progress"}, nil
+	}
+	p.calls++
+	if last := messages[len(messages)-1]; last.Role == "system" && strings.Contains(last.Content, "Do not repeat it") {
+		p.warnings++
+	}
+	args := map[string]interface{}{"path": "a.txt"}
+	if p.vary {
+		args["n"] = p.calls
+	}
+	return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{ID: "c", Name: "probe", Arguments: args}}}, nil
+}
+
+func (p *stuckProvider) GetDefaultModel() string { return "fake" }
+
+// readOnlyReads treats "read" calls as read-only and anything else as a change.
+func readOnlyReads(name string, args map[string]interface{}) bool { return name == "read" }
+
+func TestLoopGuardCatchesOscillation(t *testing.T) {
+	g := newLoopGuard(readOnlyReads)
+	a := []providers.ToolCall{{ID: "1", Name: "read", Arguments: map[string]interface{}{"path": "a"}}}
+	b := []providers.ToolCall{{ID: "2", Name: "read", Arguments: map[string]interface{}{"path": "b"}}}
+	for i, calls := range [][]providers.ToolCall{a, b, a, b} {
+		if run, abort := g.check(calls); len(run) != 1 || abort {
+			t.Fatalf("step %d: flagged too early", i)
+		}
+	}
+	run, abort := g.check(a)
+	msgs := g.answer(nil)
+	if len(run) != 0 || abort || len(msgs) != 2 || msgs[0].ToolCallID != "1" || msgs[1].Role != "system" {
+		t.Fatalf("expected the third identical call to be answered and corrected, got %+v", msgs)
+	}
+	if _, abort := g.check(b); !abort {
+		t.Fatal("expected a repeat after the correction to abort")
+	}
+}
+
+func TestLoopGuardAllowsRepeatsAfterAChange(t *testing.T) {
+	g := newLoopGuard(readOnlyReads)
+	read := providers.ToolCall{ID: "1", Name: "read", Arguments: map[string]interface{}{"path": "a"}}
+	test := providers.ToolCall{ID: "2", Name: "exec", Arguments: map[string]interface{}{"cmd": "go test"}}
+	for i := 0; i < 2*maxIdenticalCalls; i++ {
+		edit := providers.ToolCall{ID: "3", Name: "write", Arguments: map[string]interface{}{"path": "a", "n": i}}
+		for _, calls := range [][]providers.ToolCall{{read}, {edit}, {test}} {
+			if run, abort := g.check(calls); len(run) != 1 || abort {
+				t.Fatalf("round %d: %s flagged although a write came in between", i, calls[0].Name)
+			}
+		}
+	}
+	for i := 1; i < maxIdenticalCalls; i++ {
+		g.check([]providers.ToolCall{test})
+	}
+	if run, _ := g.check([]providers.ToolCall{test}); len(run) != 0 {
+		t.Fatal("expected a command repeated with nothing in between to be held back")
+	}
+}
+
+func TestLoopGuardRunsTheCallsThatAreNotRepeated(t *testing.T) {
+	g := newLoopGuard(readOnlyReads)
+	a := providers.ToolCall{ID: "a", Name: "read", Arguments: map[string]interface{}{"path": "a"}}
+	for i := 1; i < maxIdenticalCalls; i++ {
+		g.check([]providers.ToolCall{a})
+	}
+	b := providers.ToolCall{ID: "b", Name: "read", Arguments: map[string]interface{}{"path": "b"}}
+	run, abort := g.check([]providers.ToolCall{a, b})
+	if abort || len(run) != 1 || run[0].ID != "b" {
+		t.Fatalf("expected only the new call to run, got %+v", run)
+	}
+	msgs := g.answer([]providers.Message{{Role: "tool", Content: "b result", ToolCallID: "b"}})
+	if len(msgs) != 3 || msgs[1].ToolCallID != "a" || msgs[1].Content != "(not run) repeated tool call" || msgs[2].Role != "system" {
+		t.Fatalf("expected the repeated call answered with a note after the results, got %+v", msgs)
+	}
+}
+
+func TestAgentStopsRepeatedToolCallsAndWrapsUp(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &stuckProvider{}
+	ag := NewAgentLoop(b, p, "fake", 20, t.TempDir(), nil)
+	probe := &probeTool{got: make(chan tools.InvocationContext, 20)}
+	ag.tools.Register(probe)
+
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", Content: "read a.txt"})
+	if out := <-b.Out; out.Content != "summary of progress" {
+		t.Fatalf("expected the wrap-up summary as the reply, got %q", out.Content)
+	}
+	if n := len(probe.got); n != maxIdenticalCalls-1 {
+		t.Fatalf("expected the tool to run %d times, ran %d", maxIdenticalCalls-1, n)
+	}
+	if p.warnings != 1 || p.calls != maxIdenticalCalls+1 || !strings.HasPrefix(p.wrapUp, wrapUpLooping) {
+		t.Fatalf("expected one correction, then abort and a wrap-up: calls=%d warnings=%d wrapUp=%q", p.calls, p.warnings, p.wrapUp)
+	}
+	for _, m := range ag.sessions.GetOrCreate("telegram:1").History {
+		if m.Role == "system" {
+			t.Fatalf("the correction should not be saved to history: %+v", m)
+		}
+	}
+}
+
+func TestAgentWrapsUpWhenIterationsRunOut(t *testing.T) {
+	p := &stuckProvider{vary: true}
+	ag := NewAgentLoop(chat.NewHub(10), p, "fake", 4, t.TempDir(), nil)
+	ag.tools.Register(&probeTool{got: make(chan tools.InvocationContext, 20)})
+
+	reply, err := ag.ProcessDirect("do many things", time.Second)
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if reply != "summary of progress" || p.calls != 4 || !strings.HasPrefix(p.wrapUp, wrapUpExhausted) {
+		t.Fatalf("expected a wrap-up after 4 calls, got reply=%q calls=%d wrapUp=%q", reply, p.calls, p.wrapUp)
+	}
+}
+
+// silentProvider calls the probe tool once, then answers with no text.
+type silentProvider struct{}
+
+func (silentProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	if messages[len(messages)-1].Role == "tool" {
+		return providers.LLMResponse{}, nil
+	}
+	return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{ID: "1", Name: "probe"}}}, nil
+}
+func (silentProvider) GetDefaultModel() string { return "fake" }
+
+func TestAgentNeverRepliesWithRawToolOutput(t *testing.T) {
+	b := chat.NewHub(10)
+	ag := NewAgentLoop(b, silentProvider{}, "fake", 5, t.TempDir(), nil)
+	ag.tools.Register(&probeTool{got: make(chan tools.InvocationContext, 2)})
+
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", Content: "check"})
+	if out := <-b.Out; out.Content == "ok" {
+		t.Fatal("expected the tool result not to be sent as the reply")
+	}
+}
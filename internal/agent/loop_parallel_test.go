@@ -0,0 +1,88 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// gateTool is parallel-safe. Each call waits (briefly) until `wait` calls are
+// in flight together, so running a batch sequentially would be noticed.
+type gateTool struct {
+	wait int
+
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (g *gateTool) Name() string                                  { return "gate" }
+func (g *gateTool) Description() string                           { return "waits for its siblings" }
+func (g *gateTool) Parameters() map[string]interface{}            { return nil }
+func (g *gateTool) ParallelSafe(args map[string]interface{}) bool { return true }
+func (g *gateTool) running() int                                  { g.mu.Lock(); defer g.mu.Unlock(); return g.inFlight }
+func (g *gateTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	g.mu.Lock()
+	g.inFlight++
+	if g.inFlight > g.maxSeen {
+		g.maxSeen = g.inFlight
+	}
+	g.mu.Unlock()
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for g.running() < g.wait && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	g.mu.Lock()
+	g.inFlight--
+	g.mu.Unlock()
+	return fmt.Sprint(args["id"]), nil
+}
+
+// soloTool is not parallel-safe; it records whether any gate call overlapped it.
+type soloTool struct {
+	gate       *gateTool
+	overlapped bool
+}
+
+func (s *soloTool) Name() string                       { return "solo" }
+func (s *soloTool) Description() string                { return "runs alone" }
+func (s *soloTool) Parameters() map[string]interface{} { return nil }
+func (s *soloTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	s.overlapped = s.gate.running() > 0
+	return "solo", nil
+}
+
+func TestRunToolsParallelizesSafeCallsInOrder(t *testing.T) {
+	ag := NewAgentLoop(chat.NewHub(10), &FailingProvider{}, "m", 3, t.TempDir(), nil)
+	gate := &gateTool{wait: 3}
+	solo := &soloTool{gate: gate}
+	ag.tools.Register(gate)
+	ag.tools.Register(solo)
+
+	call := func(name string, id int) providers.ToolCall {
+		return providers.ToolCall{ID: fmt.Sprint(id), Name: name, Arguments: map[string]interface{}{"id": id}}
+	}
+	calls := []providers.ToolCall{call("gate", 1), call("gate", 2), call("gate", 3), call("solo", 4), call("gate", 5)}
+
+	results := ag.runTools(context.Background(), ag.tools, calls)
+	want := []string{"1", "2", "3", "solo", "5"}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, r := range results {
+		if r.err != nil || r.content != want[i] {
+			t.Fatalf("result %d = %q, %v; want %q", i, r.content, r.err, want[i])
+		}
+	}
+	if gate.maxSeen != 3 {
+		t.Fatalf("expected the three leading gate calls to run together, max in flight was %d", gate.maxSeen)
+	}
+	if solo.overlapped {
+		t.Fatal("a call that is not parallel-safe must run alone")
+	}
+}
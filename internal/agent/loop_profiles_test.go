@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
+)
+
+// capturingProvider records the model, options and estimated prompt size of
+// every call.
+type capturingProvider struct {
+	mu      sync.Mutex
+	models  []string
+	opts    []providers.ChatOptions
+	prompts []int
+}
+
+func (p *capturingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	p.opts = append(p.opts, opts)
+	p.prompts = append(p.prompts, estimateMessagesTokens(messages))
+	return providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *capturingProvider) GetDefaultModel() string { return "default-model" }
+
+func TestAgentSelectsProfilePerChannelAndMetadata(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &capturingProvider{}
+	ag := NewAgentLoop(b, p, "strong-model", 3, t.TempDir(), nil)
+	ag.SetChatOptions(providers.ChatOptions{MaxTokens: 4096})
+	ag.SetProfiles(map[string]ModelProfile{
+		"fast": {Model: "fast-model", Options: providers.ChatOptions{MaxTokens: 256}},
+	}, map[string]string{"heartbeat": "fast"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	inbound := []chat.Inbound{
+		{Channel: "telegram", SenderID: "u", ChatID: "1", Content: "hello"},
+		{Channel: "heartbeat", SenderID: "heartbeat", ChatID: "system", Content: "check"},
+		{Channel: "telegram", SenderID: "cron", ChatID: "1", Content: "reminder", Metadata: map[string]interface{}{"profile": "fast"}},
+	}
+	for _, in := range inbound {
+		b.In <- in
+		select {
+		case <-b.Out:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for reply to %q", in.Content)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	want := []string{"strong-model", "fast-model", "fast-model"}
+	for i, m := range want {
+		if p.models[i] != m {
+			t.Fatalf("call %d: expected model %q, got %q", i, m, p.models[i])
+		}
+	}
+	if p.opts[0].MaxTokens != 4096 || p.opts[1].MaxTokens != 256 {
+		t.Fatalf("unexpected options: %+v", p.opts)
+	}
+}
+
+func TestAgentBudgetsPromptToTheTurnsModel(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &capturingProvider{}
+	ag := NewAgentLoop(b, p, "big-model", 3, t.TempDir(), nil)
+	ag.SetProfiles(map[string]ModelProfile{"small": {Model: "small-model"}}, map[string]string{"discord": "small"})
+	windows := map[string]int{"big-model": 1000000, "small-model": 4000}
+	ag.SetContextWindow(func(model string) int { return windows[model] }, 0)
+
+	for _, key := range []string{"telegram:1", "discord:1"} {
+		sess := ag.sessions.GetOrCreate(key)
+		for i := 0; i < session.SummarizeThreshold-5; i++ { // stay below summarizing
+			sess.AddMessage("user", strings.Repeat("h", 600)) // ~150 tokens each
+		}
+	}
+	for _, channel := range []string{"telegram", "discord"} {
+		ag.processMessage(context.Background(), chat.Inbound{Channel: channel, ChatID: "1", SenderID: "u", Content: "hi"})
+		<-b.Out
+	}
+
+	if p.models[0] != "big-model" || p.prompts[0] < windows["small-model"] {
+		t.Fatalf("expected the default model to get the full history, got %s with ~%d tokens", p.models[0], p.prompts[0])
+	}
+	if p.models[1] != "small-model" || p.prompts[1] > windows["small-model"] {
+		t.Fatalf("expected the profile's prompt trimmed to its %d-token window, got %s with ~%d tokens", windows["small-model"], p.models[1], p.prompts[1])
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// reasoningProvider answers with separate reasoning output.
+type reasoningProvider struct{}
+
+func (p *reasoningProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	return providers.LLMResponse{Content: "The answer is 4.", Reasoning: "2+2 is 4"}, nil
+}
+
+func (p *reasoningProvider) GetDefaultModel() string { return "r1" }
+
+func TestAgentSavesReasoningWithoutReplyingWithIt(t *testing.T) {
+	ws := t.TempDir()
+	ag := NewAgentLoop(chat.NewHub(10), &reasoningProvider{}, "r1", 3, ws, nil)
+	ag.SetReasoningLog(filepath.Join(ws, "reasoning"))
+
+	out, err := ag.ProcessDirect("what is 2+2?", 2*time.Second)
+	if err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	if strings.Contains(out, "2+2 is 4") {
+		t.Fatalf("reasoning leaked into the reply: %q", out)
+	}
+
+	b, err := os.ReadFile(filepath.Join(ws, "reasoning", time.Now().UTC().Format("2006-01-02")+".jsonl"))
+	if err != nil {
+		t.Fatalf("expected reasoning file: %v", err)
+	}
+	if !strings.Contains(string(b), `"reasoning":"2+2 is 4"`) || !strings.Contains(string(b), `"model":"r1"`) {
+		t.Fatalf("unexpected reasoning log: %s", b)
+	}
+}
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// TestAgentToolFlowReplaysFromCassette records a tool-calling conversation and
+// replays it through a fresh agent without the original provider.
+func TestAgentToolFlowReplaysFromCassette(t *testing.T) {
+	ws := t.TempDir()
+	cassette := filepath.Join(t.TempDir(), "tool_flow.json")
+
+	rec := providers.NewRecordingProvider(&FakeProvider{}, cassette)
+	ag := NewAgentLoop(chat.NewHub(10), rec, "fake", 3, ws, nil)
+	want, err := ag.ProcessDirect("trigger", 2*time.Second)
+	if err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+
+	rp, err := providers.NewReplayProvider(cassette)
+	if err != nil {
+		t.Fatalf("load cassette: %v", err)
+	}
+	hub := chat.NewHub(10)
+	ag = NewAgentLoop(hub, rp, "fake", 3, ws, nil)
+	got, err := ag.ProcessDirect("trigger", 2*time.Second)
+	if err != nil {
+		t.Fatalf("replay run: %v", err)
+	}
+	if got != want || got != "All done!" {
+		t.Fatalf("expected replayed reply %q, got %q", want, got)
+	}
+	// the replayed tool call must still have executed the message tool
+	select {
+	case out := <-hub.Out:
+		if out.Content != "hello from tool" {
+			t.Fatalf("unexpected tool output %q", out.Content)
+		}
+	default:
+		t.Fatalf("expected the message tool to run during replay")
+	}
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+)
+
+// startSlowTurn sends a "slow" message to chat 1 and waits until its
+// provider call is in flight.
+func startSlowTurn(t *testing.T, b *chat.Hub, p *blockingProvider) {
+	t.Helper()
+	b.In <- chat.Inbound{Channel: "telegram", ChatID: "1", Content: "slow"}
+	deadline := time.Now().Add(2 * time.Second)
+	for p.running() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the turn to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func nextReply(t *testing.T, b *chat.Hub) string {
+	t.Helper()
+	select {
+	case out := <-b.Out:
+		return out.Content
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for a reply")
+		return ""
+	}
+}
+
+func TestAgentStopCancelsRunningTurn(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &blockingProvider{release: make(chan struct{})}
+	ag := NewAgentLoop(b, p, "test-model", 3, t.TempDir(), nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	startSlowTurn(t, b, p)
+	b.In <- chat.Inbound{Channel: "telegram", ChatID: "1", Content: "/stop"}
+	if got := nextReply(t, b); got != stoppedReply {
+		t.Fatalf("expected %q, got %q", stoppedReply, got)
+	}
+
+	// With nothing running, /stop is answered by its command.
+	b.In <- chat.Inbound{Channel: "telegram", ChatID: "1", Content: "/stop"}
+	if got := nextReply(t, b); got != "Nothing to stop." {
+		t.Fatalf("unexpected reply: %q", got)
+	}
+}
+
+func TestAgentNewMessageInterruptsWhenEnabled(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &blockingProvider{release: make(chan struct{})}
+	ag := NewAgentLoop(b, p, "test-model", 3, t.TempDir(), nil)
+	ag.SetInterruptOnNewMessage(true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	startSlowTurn(t, b, p)
+	b.In <- chat.Inbound{Channel: "telegram", ChatID: "1", Content: "never mind"}
+	if got := nextReply(t, b); got != stoppedReply {
+		t.Fatalf("expected %q, got %q", stoppedReply, got)
+	}
+	if got := nextReply(t, b); got != "never mind" {
+		t.Fatalf("expected the new message to be answered, got %q", got)
+	}
+}
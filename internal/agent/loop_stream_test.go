@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// streamingProvider emits a few deltas through ChatStream and fails the test if
+// the non-streaming path is used.
+type streamingProvider struct {
+	t *testing.T
+}
+
+func (p *streamingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	p.t.Errorf("Chat should not be called when streaming is enabled")
+	return providers.LLMResponse{}, nil
+}
+
+func (p *streamingProvider) ChatStream(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions, onDelta func(string)) (providers.LLMResponse, error) {
+	onDelta("Hello")
+	onDelta(", world")
+	return providers.LLMResponse{Content: "Hello, world"}, nil
+}
+
+func (p *streamingProvider) GetDefaultModel() string { return "stream" }
+
+func TestAgentForwardsPartialReplies(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &streamingProvider{t: t}
+	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 3, t.TempDir(), nil)
+	ag.SetStreaming(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "cli", SenderID: "user", ChatID: "one", Content: "hi"}
+
+	sawPartial := false
+	deadline := time.After(1 * time.Second)
+	for {
+		select {
+		case out := <-b.Out:
+			if out.Partial {
+				if out.Content != "Hello" {
+					t.Fatalf("expected first partial to carry text so far, got %q", out.Content)
+				}
+				sawPartial = true
+				continue
+			}
+			if out.Content != "Hello, world" {
+				t.Fatalf("unexpected final content %q", out.Content)
+			}
+			if !sawPartial {
+				t.Fatalf("expected a partial reply before the final one")
+			}
+			return
+		case <-deadline:
+			t.Fatalf("timeout waiting for final response")
+		}
+	}
+}
+
+// resettingProvider streams some text, abandons it, and then answers.
+type resettingProvider struct{ streamingProvider }
+
+func (p *resettingProvider) ChatStream(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions, onDelta func(string)) (providers.LLMResponse, error) {
+	onDelta("Hel")
+	onDelta(providers.StreamReset)
+	onDelta("Bye")
+	return providers.LLMResponse{Content: "Bye"}, nil
+}
+
+func TestAgentClearsPartialReplyOnStreamReset(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &resettingProvider{streamingProvider{t: t}}
+	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 3, t.TempDir(), nil)
+	ag.SetStreaming(true)
+
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "cli", ChatID: "one", Content: "hi"})
+	var partials []string
+	var streamID string
+	for out := range b.Out {
+		if !out.Partial {
+			if out.Content != "Bye" || out.StreamID == "" || out.StreamID != streamID {
+				t.Fatalf("expected the final reply to finish stream %q, got %+v", streamID, out)
+			}
+			break
+		}
+		streamID = out.StreamID
+		partials = append(partials, out.Content)
+	}
+	if len(partials) != 2 || partials[0] != "Hel" || partials[1] != streamResetPreview {
+		t.Fatalf("expected the abandoned text to be blanked out, got %q", partials)
+	}
+}
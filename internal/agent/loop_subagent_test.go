@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+)
+
+// spawningProvider makes the main turn spawn a subagent and answers the
+// subagent's own call, recording which tools the subagent was offered.
+type spawningProvider struct {
+	mu            sync.Mutex
+	subagentTools []string
+}
+
+func (p *spawningProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	last := messages[len(messages)-1]
+	switch {
+	case strings.Contains(last.Content, "Task: research X"):
+		p.mu.Lock()
+		for _, d := range tools {
+			p.subagentTools = append(p.subagentTools, d.Name)
+		}
+		p.mu.Unlock()
+		return providers.LLMResponse{Content: "X is great"}, nil
+	case last.Role == "tool":
+		return providers.LLMResponse{Content: "I'm on it."}, nil
+	default:
+		return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{
+			ID: "1", Name: "spawn", Arguments: map[string]interface{}{"task": "research X"},
+		}}}, nil
+	}
+}
+
+func (p *spawningProvider) GetDefaultModel() string { return "fake" }
+
+func TestAgentSpawnedSubagentReportsBack(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &spawningProvider{}
+	ag := NewAgentLoop(b, p, "fake", 5, t.TempDir(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "9", Content: "research X and get back to me"}
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case out := <-b.Out:
+			if out.ChatID != "9" {
+				t.Fatalf("unexpected chat %q", out.ChatID)
+			}
+			got = append(got, out.Content)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout; got %q", got)
+		}
+	}
+	joined := strings.Join(got, "\n")
+	if !strings.Contains(joined, "I'm on it.") || !strings.Contains(joined, "X is great") {
+		t.Fatalf("expected the reply and the subagent report, got %q", got)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, name := range p.subagentTools {
+		if name == "spawn" || name == "message" || name == "write_memory" {
+			t.Fatalf("subagent must not be offered %q: %v", name, p.subagentTools)
+		}
+	}
+}
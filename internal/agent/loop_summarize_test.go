@@ -0,0 +1,178 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
+)
+
+// summarizingProvider replies "ok" to chat turns and "SUMMARY-n" to summary
+// requests, recording the prompts it was sent.
+type summarizingProvider struct {
+	mu        sync.Mutex
+	summaries int
+	prompts   [][]providers.Message
+}
+
+func (p *summarizingProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, messages)
+	if messages[0].Content == summarizeInstruction {
+		p.summaries++
+		return providers.LLMResponse{Content: fmt.Sprintf("SUMMARY-%d", p.summaries)}, nil
+	}
+	return providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *summarizingProvider) GetDefaultModel() string { return "fake" }
+
+func TestAgentSummarizesLongSessions(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &summarizingProvider{}
+	ag := NewAgentLoop(b, p, "fake", 3, t.TempDir(), nil)
+
+	sess := ag.sessions.GetOrCreate("telegram:1")
+	for i := 0; i < session.SummarizeThreshold-2; i++ {
+		sess.AddMessage("user", fmt.Sprintf("message %d", i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	send := func(content string) {
+		b.In <- chat.Inbound{Channel: "telegram", SenderID: "u", ChatID: "1", Content: content}
+		select {
+		case <-b.Out:
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for reply to %q", content)
+		}
+	}
+	send("first")  // reaches the threshold and triggers a summary
+	send("second") // must see the summary before the recent history
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.summaries != 1 {
+		t.Fatalf("expected one summary call, got %d", p.summaries)
+	}
+	if !strings.Contains(p.prompts[1][1].Content, "message 0") {
+		t.Fatalf("expected the oldest messages in the summary request, got %q", p.prompts[1][1].Content)
+	}
+	last := p.prompts[len(p.prompts)-1]
+	summaryAt, historyAt := -1, -1
+	for i, m := range last {
+		if strings.Contains(m.Content, "SUMMARY-1") {
+			summaryAt = i
+		}
+		if historyAt < 0 && strings.Contains(m.Content, fmt.Sprintf("message %d", session.SummarizeChunk)) {
+			historyAt = i
+		}
+		if strings.Contains(m.Content, "message 0") {
+			t.Fatalf("summarized messages must not be replayed: %q", m.Content)
+		}
+	}
+	if summaryAt < 0 || historyAt < 0 || summaryAt > historyAt {
+		t.Fatalf("expected the summary before the remaining history (summary at %d, history at %d)", summaryAt, historyAt)
+	}
+	if got := len(sess.GetHistory()); got >= session.SummarizeThreshold {
+		t.Fatalf("expected the history to shrink, got %d messages", got)
+	}
+}
+
+func TestAgentSummarizesBeforeTrimming(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &summarizingProvider{}
+	ag := NewAgentLoop(b, p, "fake", 3, t.TempDir(), nil)
+
+	// A long turn can take the history well past MaxHistorySize; nothing may
+	// be trimmed before it reaches the summary.
+	n := session.MaxHistorySize + 10
+	sess := ag.sessions.GetOrCreate("telegram:1")
+	for i := 0; i < n; i++ {
+		sess.AddMessage("user", fmt.Sprintf("message %d.", i))
+	}
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", Content: "hi"})
+	<-b.Out
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.summaries != 2 {
+		t.Fatalf("expected two summary calls to bring the history under the threshold, got %d", p.summaries)
+	}
+	if got := len(sess.GetHistory()); got >= session.SummarizeThreshold {
+		t.Fatalf("expected fewer than %d messages after summarizing, got %d", session.SummarizeThreshold, got)
+	}
+	var seen strings.Builder
+	for _, prompt := range p.prompts {
+		if prompt[0].Content == summarizeInstruction {
+			seen.WriteString(prompt[1].Content)
+		}
+	}
+	for _, m := range sess.GetHistory() {
+		seen.WriteString(m.Content)
+	}
+	for i := 0; i < n; i++ {
+		if !strings.Contains(seen.String(), fmt.Sprintf("message %d.", i)) {
+			t.Fatalf("message %d was dropped without being summarized", i)
+		}
+	}
+}
+
+// toolHeavyProvider makes `steps` tool calls before answering a turn; summary
+// requests are handled as by summarizingProvider.
+type toolHeavyProvider struct {
+	summarizingProvider
+	steps int
+}
+
+func (p *toolHeavyProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	if messages[0].Content == summarizeInstruction || p.steps == 0 {
+		return p.summarizingProvider.Chat(ctx, messages, tools, model, opts)
+	}
+	p.steps--
+	args := map[string]interface{}{"step": p.steps}
+	return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{ID: fmt.Sprint("c", p.steps), Name: "probe", Arguments: args}}}, nil
+}
+
+func TestAgentSummarizesToolHeavyTurns(t *testing.T) {
+	b := chat.NewHub(10)
+	p := &toolHeavyProvider{steps: 15}
+	ag := NewAgentLoop(b, p, "fake", 20, t.TempDir(), nil)
+	ag.tools.Register(&probeTool{got: make(chan tools.InvocationContext, 20)})
+
+	sess := ag.sessions.GetOrCreate("telegram:1")
+	for i := 0; i < session.SummarizeThreshold-5; i++ {
+		sess.AddMessage("user", fmt.Sprintf("message %d.", i))
+	}
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", Content: "do a lot"})
+	<-b.Out
+
+	history := sess.GetHistory()
+	if len(history) >= session.SummarizeThreshold {
+		t.Fatalf("expected the summarizer to keep up with the turn, got %d messages", len(history))
+	}
+	if history[0].Role == "tool" {
+		t.Fatalf("the history must not start with a tool result cut off from its call: %+v", history[0])
+	}
+	if p.summaries < 2 {
+		t.Fatalf("expected the turn's tool calls to need more than one summary, got %d", p.summaries)
+	}
+}
+
+func TestTranscriptLineKeepsToolResultsValidUTF8(t *testing.T) {
+	line := transcriptLine(session.Message{Role: "tool", Content: "x" + strings.Repeat("ü", summaryToolResultLen)}) // a character straddles the cap
+	if !utf8.ValidString(line) || !strings.HasSuffix(line, "ü...") {
+		t.Fatalf("expected the tool result cut between characters, got %q", line)
+	}
+}
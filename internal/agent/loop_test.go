@@ -12,7 +12,7 @@ func TestProcessDirectWithStub(t *testing.T) {
 	b := chat.NewHub(10)
 	p := providers.NewStubProvider()
 
-	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 5, "", nil)
+	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 5, t.TempDir(), nil)
 
 	resp, err := ag.ProcessDirect("hello", 1*time.Second)
 	if err != nil {
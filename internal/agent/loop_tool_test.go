@@ -2,9 +2,12 @@ package agent
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/local/picobot/internal/agent/tools"
 	"github.com/local/picobot/internal/chat"
 	"github.com/local/picobot/internal/providers"
 )
@@ -14,7 +17,7 @@ type FakeProvider struct {
 	count int
 }
 
-func (f *FakeProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string) (providers.LLMResponse, error) {
+func (f *FakeProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
 	f.count++
 	if f.count == 1 {
 		// request message tool
@@ -31,7 +34,7 @@ func (f *FakeProvider) GetDefaultModel() string { return "fake" }
 func TestAgentExecutesToolCall(t *testing.T) {
 	b := chat.NewHub(10)
 	p := &FakeProvider{}
-	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 3, "", nil)
+	ag := NewAgentLoop(b, p, p.GetDefaultModel(), 3, t.TempDir(), nil)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
@@ -59,3 +62,98 @@ func TestAgentExecutesToolCall(t *testing.T) {
 		}
 	}
 }
+
+// probeTool records the InvocationContext it is called with.
+type probeTool struct {
+	got chan tools.InvocationContext
+}
+
+func (p *probeTool) Name() string                       { return "probe" }
+func (p *probeTool) Description() string                { return "records its invocation" }
+func (p *probeTool) Parameters() map[string]interface{} { return nil }
+func (p *probeTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	inv, _ := tools.InvocationFrom(ctx)
+	p.got <- inv
+	return "ok", nil
+}
+
+// probeProvider calls the probe tool once per turn, then replies.
+type probeProvider struct{}
+
+func (probeProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	if messages[len(messages)-1].Role == "tool" {
+		return providers.LLMResponse{Content: "done"}, nil
+	}
+	return providers.LLMResponse{HasToolCalls: true, ToolCalls: []providers.ToolCall{{ID: "1", Name: "probe"}}}, nil
+}
+func (probeProvider) GetDefaultModel() string { return "fake" }
+
+func TestAgentPassesInvocationContextToTools(t *testing.T) {
+	b := chat.NewHub(10)
+	ag := NewAgentLoop(b, probeProvider{}, "fake", 3, t.TempDir(), nil)
+	probe := &probeTool{got: make(chan tools.InvocationContext, 2)}
+	ag.tools.Register(probe)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "alice", ChatID: "42", Content: "hi"}
+	var first tools.InvocationContext
+	select {
+	case first = <-probe.got:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for tool call")
+	}
+	want := tools.InvocationContext{Channel: "telegram", ChatID: "42", SenderID: "alice", SessionKey: "telegram:42", TurnID: first.TurnID}
+	if first != want || first.TurnID == "" {
+		t.Fatalf("unexpected invocation context: %+v", first)
+	}
+	<-b.Out
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "alice", ChatID: "42", Content: "again"}
+	select {
+	case second := <-probe.got:
+		if second.TurnID == first.TurnID {
+			t.Fatalf("expected a new turn ID, got %q twice", second.TurnID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for second tool call")
+	}
+}
+
+func TestAgentStoresToolCallsInSession(t *testing.T) {
+	b := chat.NewHub(10)
+	ag := NewAgentLoop(b, probeProvider{}, "fake", 3, t.TempDir(), nil)
+	ag.tools.Register(&probeTool{got: make(chan tools.InvocationContext, 1)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go ag.Run(ctx)
+
+	b.In <- chat.Inbound{Channel: "telegram", SenderID: "alice", ChatID: "3", Content: "probe it"}
+	select {
+	case <-b.Out:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reply")
+	}
+
+	h := ag.sessions.GetOrCreate("telegram:3").GetHistory()
+	if len(h) != 4 {
+		t.Fatalf("expected user, tool call, tool result and reply, got %+v", h)
+	}
+	if h[0].Role != "user" || h[0].SenderID != "alice" ||
+		h[1].Role != "assistant" || len(h[1].ToolCalls) != 1 ||
+		h[2].Role != "tool" || h[2].ToolCallID != h[1].ToolCalls[0].ID ||
+		h[3].Role != "assistant" || h[3].Content != "done" {
+		t.Fatalf("unexpected history: %+v", h)
+	}
+}
+
+func TestSessionMessageTruncatesToolResultsOnRuneBoundary(t *testing.T) {
+	long := "x" + strings.Repeat("日", maxStoredToolResult) // a rune straddles the cap
+	m := sessionMessage(providers.Message{Role: "tool", Content: long, ToolCallID: "1"})
+	if !utf8.ValidString(m.Content) || !strings.HasSuffix(m.Content, "...(truncated)") || len(m.Content) > maxStoredToolResult+len("\n...(truncated)") {
+		t.Fatalf("expected valid UTF-8 capped at %d bytes, got %d bytes", maxStoredToolResult, len(m.Content))
+	}
+}
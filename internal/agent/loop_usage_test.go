@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/usage"
+)
+
+// failedOverProvider answers as if a failover served the request with another model.
+type failedOverProvider struct{}
+
+func (failedOverProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	return providers.LLMResponse{Content: "hi", Model: "backup-model", Usage: providers.Usage{PromptTokens: 10, CompletionTokens: 2}}, nil
+}
+func (failedOverProvider) GetDefaultModel() string { return "primary-model" }
+
+func TestAgentRecordsUsageUnderServedModel(t *testing.T) {
+	ws := t.TempDir()
+	ag := NewAgentLoop(chat.NewHub(10), failedOverProvider{}, "primary-model", 3, ws, nil)
+	ag.SetUsageTracker(usage.NewTracker(ws))
+
+	if _, err := ag.ProcessDirect("hello", time.Second); err != nil {
+		t.Fatalf("ProcessDirect: %v", err)
+	}
+	records, err := usage.Load(ws, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(records) != 1 || records[0].Model != "backup-model" || records[0].PromptTokens != 10 {
+		t.Fatalf("expected one record for the model that answered, got %+v", records)
+	}
+}
+
+func TestAgentChargesMemoryRankingToTheTurnsChat(t *testing.T) {
+	ws := t.TempDir()
+	b := chat.NewHub(10)
+	ag := NewAgentLoop(b, failedOverProvider{}, "primary-model", 3, ws, nil)
+	ag.SetUsageTracker(usage.NewTracker(ws))
+	ag.memory.AddShort("buy milk") // gives the ranker something to rank
+
+	ag.processMessage(context.Background(), chat.Inbound{Channel: "telegram", ChatID: "1", Content: "hello"})
+	<-b.Out
+	records, err := usage.Load(ws, 1)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected a record for the ranking and one for the reply, got %+v", records)
+	}
+	for _, r := range records {
+		if r.Channel != "telegram" || r.ChatID != "1" || r.TurnID == "" || r.TurnID != records[0].TurnID {
+			t.Fatalf("expected both records charged to the chat's turn, got %+v", records)
+		}
+	}
+	if totals := usage.Summarize(records, usage.KeyFuncs["chat"], nil); totals[0].Turns != 1 {
+		t.Fatalf("expected one turn, got %+v", totals)
+	}
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/providers"
+)
+
+// maxIdenticalCalls is how often a turn may make the same tool call (same name
+// and arguments) with nothing changed in between before it is treated as a
+// loop. Counting over the whole turn, not just consecutive calls, also catches
+// oscillation, such as reading two files in turn.
+const maxIdenticalCalls = 3
+
+// loopGuard notices a model repeating tool calls within one turn.
+type loopGuard struct {
+	// readOnly reports whether a call leaves state unchanged; any other call
+	// may change what the turn's earlier calls would return.
+	readOnly func(name string, args map[string]interface{}) bool
+	counts   map[string]int
+	warned   bool
+	held     []providers.ToolCall // repeated calls from the last check
+	repeated string               // name of the first of them
+}
+
+// newLoopGuard returns a guard for one turn. Calls for which readOnly is false
+// restart the count of every other call; a nil readOnly treats all calls as
+// read-only.
+func newLoopGuard(readOnly func(name string, args map[string]interface{}) bool) *loopGuard {
+	if readOnly == nil {
+		readOnly = func(string, map[string]interface{}) bool { return true }
+	}
+	return &loopGuard{readOnly: readOnly, counts: make(map[string]int)}
+}
+
+// check is called with each tool-calling response and returns the calls to
+// run. A call repeated too often is held back, to be answered by answer. If
+// the model repeats a call again after being corrected, abort is set and the
+// caller should stop and wrap up.
+func (g *loopGuard) check(calls []providers.ToolCall) (run []providers.ToolCall, abort bool) {
+	g.held, g.repeated = nil, ""
+	keys := make(map[string]bool) // calls in this batch that may change state
+	for _, tc := range calls {
+		args, _ := json.Marshal(tc.Arguments) // map keys are sorted, so equal args match
+		key := tc.Name + " " + string(args)
+		g.counts[key]++
+		if g.counts[key] >= maxIdenticalCalls {
+			if g.repeated == "" {
+				g.repeated = tc.Name
+			}
+			g.held = append(g.held, tc)
+			continue
+		}
+		run = append(run, tc)
+		if !g.readOnly(tc.Name, tc.Arguments) {
+			keys[key] = true
+		}
+	}
+	if len(g.held) > 0 && g.warned {
+		return nil, true
+	}
+	if len(keys) > 0 {
+		// Results may differ after a change, so only the changing calls
+		// themselves keep counting.
+		for key := range g.counts {
+			if !keys[key] {
+				delete(g.counts, key)
+			}
+		}
+	}
+	return run, false
+}
+
+// answer appends a note for each call the last check held back and, the first
+// time there are any, a corrective system message. Call it after the results
+// of the calls that ran, so those stay next to their assistant message.
+func (g *loopGuard) answer(messages []providers.Message) []providers.Message {
+	for _, tc := range g.held {
+		messages = append(messages, providers.Message{Role: "tool", Content: "(not run) repeated tool call", ToolCallID: tc.ID})
+	}
+	if len(g.held) == 0 || g.warned {
+		return messages
+	}
+	g.warned = true
+	return append(messages, providers.Message{Role: "system", Content: fmt.Sprintf(
+		"You have called %s with the same arguments %d times, with nothing changed in between; the result will not change. "+
+			"Do not repeat it. Use the results you already have, try a different approach, or answer the user.", g.repeated, maxIdenticalCalls)})
+}
+
+const (
+	wrapUpExhausted = "You have used all your tool-calling steps for this request, so tools are now unavailable. "
+	wrapUpLooping   = "You were repeating the same tool calls without making progress, so tools are now unavailable. "
+	wrapUpAsk       = "Reply to the user: summarize what you did and found so far, and what is left unfinished."
+
+	// wrapUpFailedReply is sent when the wrap-up call fails too.
+	wrapUpFailedReply = "Sorry, I ran out of steps before finishing this. Try asking again, or break it into smaller parts."
+)
+
+// wrapUp makes one last call without tools, asking the model to report its
+// progress, for a turn that ended without an answer (looping is true if the
+// loop guard stopped it, false if it ran out of iterations). The reply is the
+// response's Content; the response is returned on error too, for its usage.
+func (a *AgentLoop) wrapUp(ctx context.Context, messages []providers.Message, model string, opts providers.ChatOptions, looping bool) (providers.LLMResponse, error) {
+	reason := wrapUpExhausted
+	if looping {
+		reason = wrapUpLooping
+	}
+	msgs := append(append([]providers.Message(nil), messages...), providers.Message{Role: "system", Content: reason + wrapUpAsk})
+	start := time.Now()
+	resp, err := a.provider.Chat(ctx, msgs, nil, model, opts)
+	a.traceProvider(ctx, model, start, resp, err)
+	if err != nil {
+		log.Printf("wrap-up call failed: %v", err)
+		return resp, err
+	}
+	inv, _ := tools.InvocationFrom(ctx)
+	a.recordReasoning(inv.Channel, inv.ChatID, model, resp.Reasoning)
+	if strings.TrimSpace(resp.Content) == "" {
+		return resp, errors.New("empty wrap-up reply")
+	}
+	return resp, nil
+}
@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,9 +10,18 @@ import (
 	"github.com/local/picobot/internal/providers"
 )
 
-func TestLLMRankerWithOpenAIFunctionCall(t *testing.T) {
-	// server returns tool_calls style response for rank_memories
+func TestLLMRankerWithOpenAIJSONSchema(t *testing.T) {
+	// server checks that a json_schema response format was requested and replies with JSON content
+	var format struct {
+		ResponseFormat struct {
+			Type       string `json:"type"`
+			JSONSchema struct {
+				Name string `json:"name"`
+			} `json:"json_schema"`
+		} `json:"response_format"`
+	}
 	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&format)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(200)
 		w.Write([]byte(`{
@@ -19,17 +29,7 @@ func TestLLMRankerWithOpenAIFunctionCall(t *testing.T) {
 		    {
 		      "message": {
 		        "role": "assistant",
-		        "content": "",
-		        "tool_calls": [
-		          {
-		            "id": "call_rank_1",
-		            "type": "function",
-		            "function": {
-		              "name": "rank_memories",
-		              "arguments": "{\"indices\": [1, 0]}"
-		            }
-		          }
-		        ]
+		        "content": "{\"indices\": [1, 0]}"
 		      }
 		    }
 		  ]
@@ -49,4 +49,7 @@ func TestLLMRankerWithOpenAIFunctionCall(t *testing.T) {
 	if res[0].Text != "call mom" {
 		t.Fatalf("expected first result to be 'call mom', got %q", res[0].Text)
 	}
+	if format.ResponseFormat.Type != "json_schema" || format.ResponseFormat.JSONSchema.Name != "memory_ranking" {
+		t.Fatalf("expected json_schema response format, got %+v", format.ResponseFormat)
+	}
 }
@@ -7,23 +7,27 @@ import (
 	"github.com/local/picobot/internal/providers"
 )
 
-// fake provider that returns the content it was asked to return
+// fake provider that returns the configured replies in order; the last one repeats
 type fakeProvider struct {
-	resp  string
-	calls []providers.ToolCall
+	resps []string
+	calls int
+	opts  providers.ChatOptions
 }
 
-func (f *fakeProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string) (providers.LLMResponse, error) {
-	if len(f.calls) > 0 {
-		return providers.LLMResponse{Content: "", HasToolCalls: true, ToolCalls: f.calls}, nil
+func (f *fakeProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	f.opts = opts
+	i := f.calls
+	if i >= len(f.resps) {
+		i = len(f.resps) - 1
 	}
-	return providers.LLMResponse{Content: f.resp, HasToolCalls: false}, nil
+	f.calls++
+	return providers.LLMResponse{Content: f.resps[i], HasToolCalls: false}, nil
 }
 func (f *fakeProvider) GetDefaultModel() string { return "test-model" }
 
 func TestLLMRankerUsesProvider(t *testing.T) {
 	mems := []MemoryItem{{Kind: "short", Text: "buy milk"}, {Kind: "short", Text: "call mom"}}
-	p := &fakeProvider{calls: []providers.ToolCall{{ID: "1", Name: "rank_memories", Arguments: map[string]interface{}{"indices": []int{1, 0}}}}}
+	p := &fakeProvider{resps: []string{`{"indices": [1, 0]}`}}
 	r := NewLLMRanker(p, "test-model")
 	res := r.Rank("milk", mems, 2)
 	if len(res) != 2 {
@@ -32,23 +36,28 @@ func TestLLMRankerUsesProvider(t *testing.T) {
 	if res[0].Text != "call mom" {
 		t.Fatalf("expected first result to be 'call mom', got %q", res[0].Text)
 	}
+	if p.opts.ResponseFormat == nil || p.opts.ResponseFormat.Schema == nil {
+		t.Fatalf("expected a JSON response format to be requested, got %+v", p.opts)
+	}
 }
 
 func TestLLMRankerFallsBackOnBadResponse(t *testing.T) {
 	mems := []MemoryItem{{Kind: "short", Text: "buy milk"}, {Kind: "short", Text: "call mom"}}
-	p := &fakeProvider{resp: "no-json-here"}
+	p := &fakeProvider{resps: []string{"no-json-here"}}
 	r := NewLLMRanker(p, "test-model")
 	res := r.Rank("milk", mems, 2)
 	// fallback should return most recent-first by default (SimpleRanker behavior)
 	if len(res) != 2 {
 		t.Fatalf("expected 2 results, got %d", len(res))
 	}
+	if p.calls != 2 {
+		t.Fatalf("expected one repair retry, got %d calls", p.calls)
+	}
 }
 
-func TestLLMRankerParsesFloatIndicesFromToolCall(t *testing.T) {
+func TestLLMRankerRepairsInvalidReply(t *testing.T) {
 	mems := []MemoryItem{{Kind: "short", Text: "buy milk"}, {Kind: "short", Text: "call mom"}, {Kind: "long", Text: "big fact"}}
-	// provider returns indices as []float64 (common when unmarshalling JSON numbers)
-	p := &fakeProvider{calls: []providers.ToolCall{{ID: "1", Name: "rank_memories", Arguments: map[string]interface{}{"indices": []float64{2, 0}}}}}
+	p := &fakeProvider{resps: []string{"Result: [2,0]", `{"indices": [2, 0]}`}}
 	r := NewLLMRanker(p, "test-model")
 	res := r.Rank("milk", mems, 2)
 	if len(res) != 2 {
@@ -59,9 +68,9 @@ func TestLLMRankerParsesFloatIndicesFromToolCall(t *testing.T) {
 	}
 }
 
-func TestLLMRankerParsesArrayFromContentText(t *testing.T) {
+func TestLLMRankerAcceptsFencedJSON(t *testing.T) {
 	mems := []MemoryItem{{Kind: "short", Text: "buy milk"}, {Kind: "short", Text: "call mom"}}
-	p := &fakeProvider{resp: "Result: [1,0]"}
+	p := &fakeProvider{resps: []string{"```json\n{\"indices\": [1, 0]}\n```"}}
 	r := NewLLMRanker(p, "test-model")
 	res := r.Rank("milk", mems, 2)
 	if len(res) != 2 {
@@ -70,4 +79,30 @@ func TestLLMRankerParsesArrayFromContentText(t *testing.T) {
 	if res[0].Text != "call mom" {
 		t.Fatalf("expected first result to be 'call mom', got %q", res[0].Text)
 	}
+	if p.calls != 1 {
+		t.Fatalf("expected no retry for fenced JSON, got %d calls", p.calls)
+	}
+}
+
+func TestLLMRankerReportsUsage(t *testing.T) {
+	mems := []MemoryItem{{Kind: "short", Text: "buy milk"}, {Kind: "short", Text: "call mom"}}
+	r := NewLLMRanker(usageProvider{}, "requested")
+	var model string
+	var used providers.Usage
+	var chat interface{}
+	r.SetUsageFunc(func(ctx context.Context, m string, u providers.Usage) { model, used, chat = m, u, ctx.Value(chatKey{}) })
+	r.RankContext(context.WithValue(context.Background(), chatKey{}, "telegram:1"), "milk", mems, 2)
+	if model != "served" || used.PromptTokens != 7 || chat != "telegram:1" {
+		t.Fatalf("expected the served model's usage to be reported with the caller's context, got %q %+v %v", model, used, chat)
+	}
+}
+
+type chatKey struct{}
+
+// usageProvider answers with a ranking, its usage, and a different served model.
+type usageProvider struct{}
+
+func (usageProvider) Chat(ctx context.Context, messages []providers.Message, tools []providers.ToolDefinition, model string, opts providers.ChatOptions) (providers.LLMResponse, error) {
+	return providers.LLMResponse{Content: `{"indices": [0]}`, Model: "served", Usage: providers.Usage{PromptTokens: 7, CompletionTokens: 1}}, nil
 }
+func (usageProvider) GetDefaultModel() string { return "requested" }
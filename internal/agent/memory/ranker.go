@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sort"
@@ -12,13 +13,20 @@ type Ranker interface {
 	Rank(query string, memories []MemoryItem, top int) []MemoryItem
 }
 
+// ContextRanker is a Ranker that can take the context of the turn it ranks
+// for, e.g. to attribute the cost of the requests it makes.
+type ContextRanker interface {
+	Ranker
+	RankContext(ctx context.Context, query string, memories []MemoryItem, top int) []MemoryItem
+}
+
 // SimpleRanker scores memories by keyword overlap with the query.
 // It's intentionally simple and deterministic for testing.
 type SimpleRanker struct{}
 
 func NewSimpleRanker() *SimpleRanker { return &SimpleRanker{} }
 
-// ErrNoIndicesFound returned when no JSON-like indices could be parsed from provider text.
+// ErrNoIndicesFound is logged when the provider returns a ranking without indices.
 var ErrNoIndicesFound = fmt.Errorf("no indices found in response")
 
 // tokenize extracts lowercase word tokens of length >= 2.
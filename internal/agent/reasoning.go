@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// reasoningRecord is one provider call's reasoning output.
+type reasoningRecord struct {
+	Time      time.Time `json:"time"`
+	Channel   string    `json:"channel"`
+	ChatID    string    `json:"chatId"`
+	Model     string    `json:"model"`
+	Reasoning string    `json:"reasoning"`
+}
+
+// reasoningLog appends model reasoning to dir/YYYY-MM-DD.jsonl for debugging.
+type reasoningLog struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func (l *reasoningLog) write(r reasoningRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	name := r.Time.UTC().Format("2006-01-02") + ".jsonl"
+	f, err := os.OpenFile(filepath.Join(l.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// SetReasoningLog enables saving the reasoning that reasoning models return
+// to dir, one JSONL file per day. Reasoning is never sent to the chat. An
+// empty dir disables it.
+func (a *AgentLoop) SetReasoningLog(dir string) {
+	if dir == "" {
+		a.reasoning = nil
+		return
+	}
+	a.reasoning = &reasoningLog{dir: dir}
+}
+
+// recordReasoning saves reasoning from one provider call, if enabled.
+func (a *AgentLoop) recordReasoning(channel, chatID, model, reasoning string) {
+	if a.reasoning == nil || reasoning == "" {
+		return
+	}
+	err := a.reasoning.write(reasoningRecord{Time: time.Now().UTC(), Channel: channel, ChatID: chatID, Model: model, Reasoning: reasoning})
+	if err != nil {
+		log.Printf("reasoning: failed to record: %v", err)
+	}
+}
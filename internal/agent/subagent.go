@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/trace"
+)
+
+const (
+	// subagentMaxIterations is a subagent's tool-calling budget.
+	subagentMaxIterations = 20
+	// subagentTimeout bounds how long a subagent may run in total.
+	subagentTimeout = 15 * time.Minute
+)
+
+// subagentTools are the tools a subagent may use. It cannot message the chat,
+// schedule jobs, spawn further subagents or change memory and skills; its
+// only output is its final report.
+var subagentTools = []string{"filesystem", "exec", "web", "list_skills", "read_skill"}
+
+const subagentInstruction = "You are a background subagent working on a single task on behalf of the main assistant. " +
+	"Nobody sees your intermediate messages and you cannot ask questions, so work autonomously with your tools until the task is done. " +
+	"Finish with a concise, self-contained report of your findings or results; it will be sent to the user as-is."
+
+// runSubagent is the tools.SubagentFunc behind the spawn tool. It runs task in
+// a child loop with an ephemeral session (no history, nothing saved), the
+// restricted subagentTools and its own iteration budget. The "subagent"
+// channel profile, if configured, selects its model.
+func (a *AgentLoop) runSubagent(ctx context.Context, task string) (report string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, subagentTimeout)
+	defer cancel()
+
+	parent, _ := tools.InvocationFrom(ctx)
+	turnID := a.nextTurnID()
+	ctx = tools.WithInvocation(ctx, tools.InvocationContext{
+		Channel:    parent.Channel,
+		ChatID:     parent.ChatID,
+		SenderID:   parent.SenderID,
+		SessionKey: "subagent:" + turnID,
+		TurnID:     turnID,
+	})
+	a.trace(ctx, trace.Event{Kind: trace.KindInbound, Text: trace.Truncate(task)})
+	defer func() {
+		e := trace.Event{Kind: trace.KindOutbound, Text: trace.Truncate(report)}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		a.trace(ctx, e)
+	}()
+
+	// Subset keeps the approval policy: a subagent's sensitive calls are
+	// approved in the chat that spawned it.
+	reg := a.tools.Subset(subagentTools)
+	toolDefs := reg.Definitions()
+
+	model, opts := a.profileFor("subagent", nil)
+	memCtx, _ := a.memory.GetMemoryContext()
+	messages := a.contextFor(model).BuildMessages(ctx, nil, "", subagentInstruction+"\n\nTask: "+task, parent.Channel, parent.ChatID, memCtx, a.memory.Recent(5), nil)
+	a.traceContext(ctx, messages)
+
+	turnUsage := usageByModel{}
+	defer func() { a.recordUsage(parent.Channel, parent.ChatID, parent.TurnID, turnUsage) }()
+	guard := newLoopGuard(reg.IsParallelSafe)
+	looping := false
+	for iteration := 0; iteration < subagentMaxIterations; iteration++ {
+		start := time.Now()
+		resp, err := a.provider.Chat(ctx, messages, toolDefs, model, opts)
+		a.traceProvider(ctx, model, start, resp, err)
+		turnUsage.add(model, resp)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("subagent stopped: %w", ctx.Err())
+			}
+			return "", err
+		}
+		a.recordReasoning(parent.Channel, parent.ChatID, model, resp.Reasoning)
+		if !resp.HasToolCalls {
+			if strings.TrimSpace(resp.Content) == "" {
+				return "", fmt.Errorf("subagent finished without a report")
+			}
+			return resp.Content, nil
+		}
+		messages = append(messages, providers.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls})
+		var run []providers.ToolCall
+		if run, looping = guard.check(resp.ToolCalls); looping {
+			break
+		}
+		for i, r := range a.runTools(ctx, reg, run) {
+			if errors.Is(r.err, tools.ErrNotApproved) {
+				return "", r.err
+			}
+			res := r.content
+			if r.err != nil {
+				res = "(tool error) " + r.err.Error()
+			}
+			messages = append(messages, providers.Message{Role: "tool", Content: res, ToolCallID: run[i].ID})
+		}
+		messages = guard.answer(messages)
+	}
+	log.Printf("subagent %s: stopped without a report (looping: %v), asking for one", turnID, looping)
+	resp, err := a.wrapUp(ctx, messages, model, opts, looping)
+	turnUsage.add(model, resp)
+	if err != nil {
+		return "", fmt.Errorf("subagent used its %d tool-calling steps without finishing", subagentMaxIterations)
+	}
+	return resp.Content, nil
+}
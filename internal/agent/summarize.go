@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/session"
+)
+
+const summarizeInstruction = "You maintain a running summary of a conversation between a user and an assistant. " +
+	"Update the summary with the new messages below. Keep facts, decisions, open tasks, names, preferences and anything the user may refer back to; " +
+	"drop small talk. Write compact plain prose or bullet points, at most about 300 words. Reply with the updated summary only."
+
+// saveSession folds the session's oldest messages into its rolling summary
+// for as long as the history is past session.SummarizeThreshold, then saves
+// it. Summarizing comes first because Save trims the history to
+// session.MaxHistorySize, which drops messages outright; that trim only comes
+// into play when summarizing fails.
+func (a *AgentLoop) saveSession(ctx context.Context, channel, chatID string, sess *session.Session) {
+	for sess.NeedsSummary() {
+		chunk := sess.OldestChunk()
+		summary, err := a.summarize(ctx, channel, chatID, sess.Summary, chunk)
+		if err != nil {
+			log.Printf("session %s: summarizing failed: %v", sess.Key, err)
+			break
+		}
+		sess.ApplySummary(summary, len(chunk))
+		log.Printf("session %s: folded %d messages into the summary", sess.Key, len(chunk))
+	}
+	if err := a.sessions.Save(sess); err != nil {
+		log.Printf("session %s: save failed: %v", sess.Key, err)
+	}
+}
+
+// summarize asks the model to merge messages into the previous summary. The
+// "summary" channel profile, if configured, selects the model.
+func (a *AgentLoop) summarize(ctx context.Context, channel, chatID, previous string, messages []session.Message) (string, error) {
+	var sb strings.Builder
+	if previous != "" {
+		sb.WriteString("Current summary:\n" + previous + "\n\n")
+	}
+	sb.WriteString("New messages:\n")
+	for _, m := range messages {
+		sb.WriteString(transcriptLine(m) + "\n")
+	}
+	model, opts := a.profileFor("summary", nil)
+	resp, err := a.provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: summarizeInstruction},
+		{Role: "user", Content: sb.String()},
+	}, nil, model, opts)
+	used := usageByModel{}
+	used.add(model, resp)
+	inv, _ := tools.InvocationFrom(ctx) // none after a command; then it counts as a turn of its own
+	a.recordUsage(channel, chatID, inv.TurnID, used)
+	if err != nil {
+		return "", err
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return "", errors.New("model returned an empty summary")
+	}
+	return summary, nil
+}
+
+// summaryToolResultLen caps how much of a tool result is shown to the summarizer.
+const summaryToolResultLen = 300
+
+// transcriptLine renders a history message for the summarizer.
+func transcriptLine(m session.Message) string {
+	switch {
+	case m.Role == "tool":
+		content := m.Content
+		if len(content) > summaryToolResultLen {
+			content = truncateUTF8(content, summaryToolResultLen) + "..."
+		}
+		return "tool result: " + content
+	case len(m.ToolCalls) > 0:
+		names := make([]string, 0, len(m.ToolCalls))
+		for _, tc := range m.ToolCalls {
+			names = append(names, tc.Name)
+		}
+		line := "assistant (called " + strings.Join(names, ", ") + ")"
+		if m.Content != "" {
+			line += ": " + m.Content
+		}
+		return line
+	}
+	return m.Role + ": " + m.Content
+}
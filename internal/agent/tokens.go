@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"encoding/json"
+
+	"github.com/local/picobot/internal/providers"
+)
+
+// Token estimates are deliberately simple: English text averages roughly four
+// bytes per token, while CJK and other non-ASCII scripts are closer to one
+// token per character. The estimate only needs to keep prompts safely under
+// the context window, not to match a particular tokenizer.
+const (
+	messageOverheadTokens = 4   // role and framing per message
+	imageTokens           = 800 // rough cost of one attached image
+)
+
+// estimateTokens approximates the number of tokens in s.
+func estimateTokens(s string) int {
+	ascii, other := 0, 0
+	for _, r := range s {
+		if r < 0x80 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// estimateMessagesTokens approximates the prompt size of msgs.
+func estimateMessagesTokens(msgs []providers.Message) int {
+	total := 0
+	for _, m := range msgs {
+		total += messageOverheadTokens + estimateTokens(m.Content)
+		for _, p := range m.Parts {
+			if p.Type == "image_url" {
+				total += imageTokens
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			b, _ := json.Marshal(tc.Arguments)
+			total += estimateTokens(tc.Name) + estimateTokens(string(b))
+		}
+	}
+	return total
+}
+
+// estimateToolsTokens approximates the prompt size of the tool definitions.
+func estimateToolsTokens(defs []providers.ToolDefinition) int {
+	b, _ := json.Marshal(defs)
+	return estimateTokens(string(b))
+}
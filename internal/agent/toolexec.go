@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/providers"
+)
+
+// maxParallelTools bounds how many parallel-safe tool calls run at once.
+const maxParallelTools = 4
+
+// toolResult is the outcome of one tool call.
+type toolResult struct {
+	content string
+	err     error
+}
+
+// runTools executes calls and returns their results in the same order.
+// Consecutive parallel-safe calls (see tools.ParallelSafe) run concurrently,
+// up to maxParallelTools at a time; any other call runs alone, after the calls
+// before it have finished. It stops early, returning fewer results, once a
+// call is not approved or ctx is canceled.
+func (a *AgentLoop) runTools(ctx context.Context, reg *tools.Registry, calls []providers.ToolCall) []toolResult {
+	results := make([]toolResult, 0, len(calls))
+	for i := 0; i < len(calls) && ctx.Err() == nil; {
+		j := i
+		for j < len(calls) && reg.IsParallelSafe(calls[j].Name, calls[j].Arguments) {
+			j++
+		}
+		if j-i > 1 {
+			batch := make([]toolResult, j-i)
+			sem := make(chan struct{}, maxParallelTools)
+			var wg sync.WaitGroup
+			for k := i; k < j; k++ {
+				wg.Add(1)
+				sem <- struct{}{}
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+					res, err := a.executeTool(ctx, reg, calls[k])
+					batch[k-i] = toolResult{content: res, err: err}
+				}()
+			}
+			wg.Wait()
+			results = append(results, batch...)
+			i = j
+			continue
+		}
+		res, err := a.executeTool(ctx, reg, calls[i])
+		results = append(results, toolResult{content: res, err: err})
+		if errors.Is(err, tools.ErrNotApproved) {
+			break
+		}
+		i++
+	}
+	return results
+}
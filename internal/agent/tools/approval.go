@@ -0,0 +1,150 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/local/picobot/internal/config"
+)
+
+// ErrNotApproved is returned by Registry.Execute when a tool call needs
+// approval and the user declined it.
+var ErrNotApproved = errors.New("tool call was not approved")
+
+// ErrNoApprover is returned by Registry.Execute, and by an Approver, when a
+// tool call needs approval but there is no one to ask, e.g. on a cron or
+// heartbeat turn. Unlike ErrNotApproved the user never saw the request.
+var ErrNoApprover = errors.New("no one can be asked to approve it here")
+
+// ApprovalRequest describes a tool call waiting for the user's approval.
+type ApprovalRequest struct {
+	Tool string
+	Args map[string]interface{}
+	// Summary is a short human-readable form of the call, e.g. "git push".
+	Summary string
+}
+
+// Approver asks the user whether a tool call may run. Approve blocks until the
+// user answers, the request times out or ctx is canceled; ctx carries the
+// InvocationContext of the turn that made the call. It returns ErrNoApprover
+// if there is no user to ask.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) (bool, error)
+}
+
+// approvalRule is a compiled config.ApprovalRule.
+type approvalRule struct {
+	tool   string
+	match  map[string]*regexp.Regexp
+	except map[string]*regexp.Regexp
+}
+
+// ApprovalPolicy decides which tool calls need approval.
+type ApprovalPolicy struct {
+	rules []approvalRule
+}
+
+// NewApprovalPolicy compiles the configured rules. It returns nil when there
+// are no rules.
+func NewApprovalPolicy(rules []config.ApprovalRule) (*ApprovalPolicy, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	p := &ApprovalPolicy{}
+	for i, r := range rules {
+		if r.Tool == "" {
+			return nil, fmt.Errorf("approval rule %d: 'tool' is required", i)
+		}
+		match, err := compileArgPatterns(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("approval rule %d (%s): %v", i, r.Tool, err)
+		}
+		except, err := compileArgPatterns(r.Except)
+		if err != nil {
+			return nil, fmt.Errorf("approval rule %d (%s): %v", i, r.Tool, err)
+		}
+		p.rules = append(p.rules, approvalRule{tool: r.Tool, match: match, except: except})
+	}
+	return p, nil
+}
+
+func compileArgPatterns(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+	out := make(map[string]*regexp.Regexp, len(patterns))
+	for arg, pat := range patterns {
+		re, err := regexp.Compile(pat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %q: %v", arg, err)
+		}
+		out[arg] = re
+	}
+	return out, nil
+}
+
+// Requires reports whether a call to the named tool with args needs approval:
+// some rule for the tool has all its match patterns matching and none of its
+// except patterns matching.
+func (p *ApprovalPolicy) Requires(name string, args map[string]interface{}) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range p.rules {
+		if r.tool == name && r.applies(args) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r approvalRule) applies(args map[string]interface{}) bool {
+	for arg, re := range r.match {
+		v, ok := args[arg]
+		if !ok || !re.MatchString(argString(v)) {
+			return false
+		}
+	}
+	for arg, re := range r.except {
+		if v, ok := args[arg]; ok && re.MatchString(argString(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// argString renders an argument for pattern matching: strings as-is, string
+// lists (such as exec's cmd) joined with spaces, anything else as JSON.
+func argString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, e := range t {
+			parts = append(parts, argString(e))
+		}
+		return strings.Join(parts, " ")
+	case []string:
+		return strings.Join(t, " ")
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// summarizeCall describes a tool call for an approval prompt.
+func summarizeCall(name string, args map[string]interface{}) string {
+	switch name {
+	case "exec":
+		if cmd, ok := args["cmd"]; ok {
+			return argString(cmd)
+		}
+	case "filesystem":
+		action, _ := args["action"].(string)
+		path, _ := args["path"].(string)
+		return fmt.Sprintf("filesystem %s %s", action, path)
+	}
+	b, _ := json.Marshal(args)
+	return name + " " + string(b)
+}
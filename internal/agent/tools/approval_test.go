@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/local/picobot/internal/config"
+)
+
+func TestApprovalPolicyMatchesArgumentPatterns(t *testing.T) {
+	p, err := NewApprovalPolicy([]config.ApprovalRule{
+		{Tool: "exec", Match: map[string]string{"cmd": `^git push`}},
+		{Tool: "filesystem", Match: map[string]string{"action": `^write$`}, Except: map[string]string{"path": `^project-`}},
+		{Tool: "delete_skill"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := []struct {
+		tool string
+		args map[string]interface{}
+		want bool
+	}{
+		{"exec", map[string]interface{}{"cmd": []interface{}{"git", "push", "origin"}}, true},
+		{"exec", map[string]interface{}{"cmd": []interface{}{"git", "status"}}, false},
+		{"filesystem", map[string]interface{}{"action": "write", "path": "notes.md"}, true},
+		{"filesystem", map[string]interface{}{"action": "write", "path": "project-x/main.go"}, false},
+		{"filesystem", map[string]interface{}{"action": "read", "path": "notes.md"}, false},
+		{"delete_skill", map[string]interface{}{"name": "weather"}, true},
+		{"web", map[string]interface{}{"url": "https://example.com"}, false},
+	}
+	for _, c := range cases {
+		if got := p.Requires(c.tool, c.args); got != c.want {
+			t.Errorf("%s %v: expected %v, got %v", c.tool, c.args, c.want, got)
+		}
+	}
+
+	if _, err := NewApprovalPolicy([]config.ApprovalRule{{Tool: "exec", Match: map[string]string{"cmd": "("}}}); err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+// fakeApprover answers every request with answer and records the summaries.
+type fakeApprover struct {
+	answer bool
+	asked  []string
+}
+
+func (f *fakeApprover) Approve(ctx context.Context, req ApprovalRequest) (bool, error) {
+	f.asked = append(f.asked, req.Summary)
+	return f.answer, nil
+}
+
+// countingTool counts its executions.
+type countingTool struct{ runs int }
+
+func (c *countingTool) Name() string                       { return "exec" }
+func (c *countingTool) Description() string                { return "counts" }
+func (c *countingTool) Parameters() map[string]interface{} { return nil }
+func (c *countingTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
+	c.runs++
+	return "ran", nil
+}
+
+func TestRegistryExecuteAsksForApproval(t *testing.T) {
+	policy, _ := NewApprovalPolicy([]config.ApprovalRule{{Tool: "exec", Match: map[string]string{"cmd": `^git push`}}})
+	tool := &countingTool{}
+	reg := NewRegistry()
+	reg.Register(tool)
+	push := map[string]interface{}{"cmd": []interface{}{"git", "push"}}
+
+	approver := &fakeApprover{answer: true}
+	reg.SetApproval(policy, approver)
+	if _, err := reg.Execute(context.Background(), "exec", push); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reg.Execute(context.Background(), "exec", map[string]interface{}{"cmd": []interface{}{"ls"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tool.runs != 2 || len(approver.asked) != 1 || approver.asked[0] != "git push" {
+		t.Fatalf("expected one approval for git push and two runs, got asked=%v runs=%d", approver.asked, tool.runs)
+	}
+
+	reg.SetApproval(policy, &fakeApprover{answer: false})
+	if _, err := reg.Execute(context.Background(), "exec", push); !errors.Is(err, ErrNotApproved) {
+		t.Fatalf("expected ErrNotApproved, got %v", err)
+	}
+	reg.SetApproval(policy, nil)
+	if _, err := reg.Execute(context.Background(), "exec", push); !errors.Is(err, ErrNoApprover) || errors.Is(err, ErrNotApproved) {
+		t.Fatalf("expected ErrNoApprover, not a refusal, without an approver, got %v", err)
+	}
+	if tool.runs != 2 {
+		t.Fatalf("declined calls must not run, got %d runs", tool.runs)
+	}
+}
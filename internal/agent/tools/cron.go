@@ -10,12 +10,10 @@ import (
 )
 
 // CronTool schedules delayed/recurring tasks via the cron scheduler.
-// It holds a channel/chatID context (set per-incoming-message) so fired jobs
-// know where to send their notification.
+// Jobs are addressed to the channel/chatID of the InvocationContext in ctx, so
+// fired jobs know where to send their notification.
 type CronTool struct {
 	scheduler *cron.Scheduler
-	channel   string
-	chatID    string
 }
 
 func NewCronTool(scheduler *cron.Scheduler) *CronTool {
@@ -56,17 +54,15 @@ func (t *CronTool) Parameters() map[string]interface{} {
 				"type":        "string",
 				"description": "For recurring jobs: how often to repeat (minimum 2m). Uses Go duration format.",
 			},
+			"profile": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional model profile name from the config (agents.profiles) to process the job with, e.g. a cheap fast model for routine checks.",
+			},
 		},
 		"required": []string{"action"},
 	}
 }
 
-// SetContext sets the originating channel and chat for scheduled jobs.
-func (t *CronTool) SetContext(channel, chatID string) {
-	t.channel = channel
-	t.chatID = chatID
-}
-
 func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	action, _ := args["action"].(string)
 
@@ -77,6 +73,7 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		delayStr, _ := args["delay"].(string)
 		recurring, _ := args["recurring"].(bool)
 		intervalStr, _ := args["interval"].(string)
+		profile, _ := args["profile"].(string)
 
 		if name == "" {
 			name = "reminder"
@@ -95,6 +92,10 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) (st
 		if delay <= 0 {
 			return "", fmt.Errorf("cron add: delay must be positive")
 		}
+		inv, ok := InvocationFrom(ctx)
+		if !ok || inv.Channel == "" {
+			return "", fmt.Errorf("cron add: no current chat to deliver the job to")
+		}
 
 		// Handle recurring jobs
 		if recurring {
@@ -109,12 +110,18 @@ func (t *CronTool) Execute(ctx context.Context, args map[string]interface{}) (st
 			if interval < 2*time.Minute {
 				return "", fmt.Errorf("cron add: recurring interval must be at least 2m (got %v)", interval)
 			}
-			id := t.scheduler.AddRecurring(name, message, interval, t.channel, t.chatID)
+			id := t.scheduler.AddRecurring(name, message, interval, inv.Channel, inv.ChatID)
+			if profile != "" {
+				t.scheduler.SetProfile(id, profile)
+			}
 			return fmt.Sprintf("Scheduled recurring job %q (id: %s). Will fire in %v, then repeat every %v.", name, id, delay, interval), nil
 		}
 
 		// One-time job
-		id := t.scheduler.Add(name, message, delay, t.channel, t.chatID)
+		id := t.scheduler.Add(name, message, delay, inv.Channel, inv.ChatID)
+		if profile != "" {
+			t.scheduler.SetProfile(id, profile)
+		}
 		return fmt.Sprintf("Scheduled job %q (id: %s). Will fire in %v.", name, id, delay), nil
 
 	case "list":
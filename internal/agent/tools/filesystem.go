@@ -59,6 +59,12 @@ func (t *FilesystemTool) Parameters() map[string]interface{} {
 	}
 }
 
+// ParallelSafe allows reads and listings to run concurrently; writes run alone.
+func (t *FilesystemTool) ParallelSafe(args map[string]interface{}) bool {
+	action, _ := args["action"].(string)
+	return action == "read" || action == "list"
+}
+
 func (t *FilesystemTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	actionRaw, ok := args["action"]
 	if !ok {
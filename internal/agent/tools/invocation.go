@@ -0,0 +1,30 @@
+package tools
+
+import "context"
+
+// InvocationContext describes the turn a tool call belongs to. The agent puts
+// it in the context.Context passed to Execute, so tools that act on "the
+// current chat" (message, cron) need no per-turn state of their own and are
+// safe to share between concurrent turns and subagents.
+type InvocationContext struct {
+	Channel  string
+	ChatID   string
+	SenderID string
+	// SessionKey is the session the turn reads and writes, usually "channel:chatID".
+	SessionKey string
+	// TurnID identifies one inbound message's processing, for logs and tracing.
+	TurnID string
+}
+
+type invocationKey struct{}
+
+// WithInvocation returns a copy of ctx carrying inv.
+func WithInvocation(ctx context.Context, inv InvocationContext) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+// InvocationFrom returns the InvocationContext carried by ctx, if any.
+func InvocationFrom(ctx context.Context) (InvocationContext, bool) {
+	inv, ok := ctx.Value(invocationKey{}).(InvocationContext)
+	return inv, ok
+}
@@ -9,11 +9,9 @@ import (
 )
 
 // MessageTool sends messages to a channel via the chat Hub.
-// It holds a context (channel + chatID) which should be set per-incoming-message.
+// The destination is the channel and chat of the InvocationContext in ctx.
 type MessageTool struct {
-	hub     *chat.Hub
-	channel string
-	chatID  string
+	hub *chat.Hub
 }
 
 func NewMessageTool(b *chat.Hub) *MessageTool {
@@ -36,12 +34,6 @@ func (m *MessageTool) Parameters() map[string]interface{} {
 	}
 }
 
-// SetContext sets the current channel and chat id for outgoing messages.
-func (m *MessageTool) SetContext(channel, chatID string) {
-	m.channel = channel
-	m.chatID = chatID
-}
-
 // Expected args: {"content": "..."}
 func (m *MessageTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
 	content := ""
@@ -57,10 +49,14 @@ func (m *MessageTool) Execute(ctx context.Context, args map[string]interface{})
 	if content == "" {
 		return "", fmt.Errorf("message tool: 'content' argument required")
 	}
+	inv, ok := InvocationFrom(ctx)
+	if !ok || inv.Channel == "" {
+		return "", fmt.Errorf("message tool: no current chat to send to")
+	}
 	// Publish outbound message to hub
 	out := chat.Outbound{
-		Channel: m.channel,
-		ChatID:  m.chatID,
+		Channel: inv.Channel,
+		ChatID:  inv.ChatID,
 		Content: content,
 	}
 	select {
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 
 	"github.com/local/picobot/internal/providers"
@@ -14,14 +15,25 @@ type Tool interface {
 	Description() string
 	// Parameters returns the JSON Schema for tool arguments (nil if no params).
 	Parameters() map[string]interface{}
-	// Execute performs the tool action and returns a string result.
+	// Execute performs the tool action and returns a string result. ctx carries
+	// the InvocationContext of the turn that made the call.
 	Execute(ctx context.Context, args map[string]interface{}) (string, error)
 }
 
+// ParallelSafe is implemented by tools whose calls may run concurrently with
+// other parallel-safe calls of the same turn, typically because they only
+// read. It is asked per call, so a tool can allow it for some actions only.
+type ParallelSafe interface {
+	ParallelSafe(args map[string]interface{}) bool
+}
+
 // Registry holds registered tools.
 type Registry struct {
 	mu    sync.RWMutex
 	tools map[string]Tool
+
+	policy   *ApprovalPolicy
+	approver Approver
 }
 
 // NewRegistry constructs a new tool registry.
@@ -36,6 +48,30 @@ func (r *Registry) Register(t Tool) {
 	r.tools[t.Name()] = t
 }
 
+// SetApproval makes Execute ask approver before running calls that policy
+// marks as needing approval. With a policy but no approver those calls are
+// refused. A nil policy disables approval.
+func (r *Registry) SetApproval(policy *ApprovalPolicy, approver Approver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = policy
+	r.approver = approver
+}
+
+// Subset returns a registry holding only the named tools that are registered
+// in r, with the same approval policy.
+func (r *Registry) Subset(names []string) *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := &Registry{tools: make(map[string]Tool), policy: r.policy, approver: r.approver}
+	for _, name := range names {
+		if t, ok := r.tools[name]; ok {
+			out.tools[name] = t
+		}
+	}
+	return out
+}
+
 // Get returns a tool by name (or nil if not found).
 func (r *Registry) Get(name string) Tool {
 	r.mu.RLock()
@@ -58,6 +94,21 @@ func (r *Registry) Definitions() []providers.ToolDefinition {
 	return defs
 }
 
+// IsParallelSafe reports whether a call may run concurrently with other
+// parallel-safe calls. Calls that need approval never do, so the user is
+// asked about them one at a time.
+func (r *Registry) IsParallelSafe(name string, args map[string]interface{}) bool {
+	r.mu.RLock()
+	t, ok := r.tools[name]
+	policy := r.policy
+	r.mu.RUnlock()
+	if !ok || policy.Requires(name, args) {
+		return false
+	}
+	ps, ok := t.(ParallelSafe)
+	return ok && ps.ParallelSafe(args)
+}
+
 // Execute executes a registered tool by name with args and returns result or error.
 func (r *Registry) Execute(ctx context.Context, name string, args map[string]interface{}) (string, error) {
 	if name == "" {
@@ -65,9 +116,26 @@ func (r *Registry) Execute(ctx context.Context, name string, args map[string]int
 	}
 	r.mu.RLock()
 	t, ok := r.tools[name]
+	policy, approver := r.policy, r.approver
 	r.mu.RUnlock()
 	if !ok {
 		return "", errors.New("tool not found")
 	}
+	if policy.Requires(name, args) {
+		summary := summarizeCall(name, args)
+		approved, err := false, ErrNoApprover
+		if approver != nil {
+			approved, err = approver.Approve(ctx, ApprovalRequest{Tool: name, Args: args, Summary: summary})
+		}
+		if errors.Is(err, ErrNoApprover) {
+			return "", fmt.Errorf("%s needs approval, but %w", summary, err)
+		}
+		if err != nil {
+			return "", err
+		}
+		if !approved {
+			return "", fmt.Errorf("%w: the user declined %s", ErrNotApproved, summary)
+		}
+	}
 	return t.Execute(ctx, args)
 }
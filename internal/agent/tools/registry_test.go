@@ -6,15 +6,16 @@ import (
 	"time"
 
 	"github.com/local/picobot/internal/chat"
+	"github.com/local/picobot/internal/config"
 )
 
 func TestMessageToolPublishesOutbound(t *testing.T) {
 	b := chat.NewHub(10)
 	mt := NewMessageTool(b)
-	mt.SetContext("cli", "test-chat")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancel()
+	ctx = WithInvocation(ctx, InvocationContext{Channel: "cli", ChatID: "test-chat"})
 	res, err := mt.Execute(ctx, map[string]interface{}{"content": "hello world"})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -28,7 +29,71 @@ func TestMessageToolPublishesOutbound(t *testing.T) {
 		if out.Content != "hello world" {
 			t.Fatalf("unexpected content: %s", out.Content)
 		}
+		if out.Channel != "cli" || out.ChatID != "test-chat" {
+			t.Fatalf("unexpected destination: %s:%s", out.Channel, out.ChatID)
+		}
 	default:
 		t.Fatalf("no outbound message published")
 	}
 }
+
+func TestMessageToolUsesInvocationContext(t *testing.T) {
+	b := chat.NewHub(10)
+	reg := NewRegistry()
+	reg.Register(NewMessageTool(b))
+
+	if _, err := reg.Execute(context.Background(), "message", map[string]interface{}{"content": "lost"}); err == nil {
+		t.Fatalf("expected an error without an invocation context")
+	}
+
+	// one shared tool instance serves overlapping turns for different chats
+	a := WithInvocation(context.Background(), InvocationContext{Channel: "telegram", ChatID: "1"})
+	c := WithInvocation(context.Background(), InvocationContext{Channel: "discord", ChatID: "2"})
+	if _, err := reg.Execute(a, "message", map[string]interface{}{"content": "to a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := reg.Execute(c, "message", map[string]interface{}{"content": "to c"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"telegram:1", "discord:2"} {
+		out := <-b.Out
+		if got := out.Channel + ":" + out.ChatID; got != want {
+			t.Fatalf("expected message for %s, got %s", want, got)
+		}
+	}
+}
+
+func TestRegistryIsParallelSafe(t *testing.T) {
+	fs, err := NewFilesystemTool(t.TempDir())
+	if err != nil {
+		t.Fatalf("filesystem tool: %v", err)
+	}
+	reg := NewRegistry()
+	reg.Register(fs)
+	reg.Register(NewWebTool())
+	reg.Register(NewExecTool(5))
+	web := map[string]interface{}{"url": "https://example.com"}
+
+	cases := []struct {
+		name string
+		args map[string]interface{}
+		want bool
+	}{
+		{"filesystem", map[string]interface{}{"action": "read", "path": "a"}, true},
+		{"filesystem", map[string]interface{}{"action": "write", "path": "a"}, false},
+		{"web", web, true},
+		{"exec", map[string]interface{}{"cmd": []interface{}{"ls"}}, false},
+		{"missing", nil, false},
+	}
+	for _, tc := range cases {
+		if got := reg.IsParallelSafe(tc.name, tc.args); got != tc.want {
+			t.Errorf("IsParallelSafe(%s, %v) = %v, want %v", tc.name, tc.args, got, tc.want)
+		}
+	}
+
+	policy, _ := NewApprovalPolicy([]config.ApprovalRule{{Tool: "web"}})
+	reg.SetApproval(policy, nil)
+	if reg.IsParallelSafe("web", web) {
+		t.Fatal("calls needing approval must not run in parallel")
+	}
+}
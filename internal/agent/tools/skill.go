@@ -208,6 +208,9 @@ func NewListSkillsTool(manager *SkillManager) *ListSkillsTool {
 
 func (t *ListSkillsTool) Name() string { return "list_skills" }
 
+// ParallelSafe reports that listing can run concurrently; it only reads.
+func (t *ListSkillsTool) ParallelSafe(args map[string]interface{}) bool { return true }
+
 func (t *ListSkillsTool) Description() string {
 	return "List all available skills with their names and descriptions"
 }
@@ -245,6 +248,9 @@ func NewReadSkillTool(manager *SkillManager) *ReadSkillTool {
 
 func (t *ReadSkillTool) Name() string { return "read_skill" }
 
+// ParallelSafe reports that reading can run concurrently.
+func (t *ReadSkillTool) ParallelSafe(args map[string]interface{}) bool { return true }
+
 func (t *ReadSkillTool) Description() string {
 	return "Read the full content of a skill by name"
 }
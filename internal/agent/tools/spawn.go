@@ -3,29 +3,82 @@ package tools
 import (
 	"context"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
 )
 
-// SpawnTool creates a background subagent; stubbed for v0.
-// Args: {"agent": "name", "task": "..."}
+// SubagentFunc runs a subagent on task until it finishes and returns its final
+// report. The agent package supplies it; ctx carries the spawning turn's
+// InvocationContext and is canceled by the cancel action.
+type SubagentFunc func(ctx context.Context, task string) (string, error)
+
+// maxRunningSubagents caps how many subagents may run at once.
+const maxRunningSubagents = 3
 
-type SpawnTool struct{}
+// keepFinishedSubagents is how many finished subagents are kept for status/list.
+const keepFinishedSubagents = 20
+
+// subagent is one spawned background task.
+type subagent struct {
+	id       string
+	name     string
+	task     string
+	channel  string
+	chatID   string
+	status   string // "running" | "done" | "failed" | "canceled"
+	result   string
+	started  time.Time
+	finished time.Time
+	cancel   context.CancelFunc
+}
+
+// SpawnTool runs tasks in background subagents so long jobs ("research X and
+// get back to me") don't block the conversation. When a subagent finishes,
+// its report is sent to the chat that spawned it.
+// Args: {"action": "spawn|list|status|cancel", "task": "...", "name": "...", "id": "..."}
+type SpawnTool struct {
+	hub *chat.Hub
+	run SubagentFunc
+
+	mu     sync.Mutex
+	nextID int
+	agents map[string]*subagent
+}
 
-func NewSpawnTool() *SpawnTool { return &SpawnTool{} }
+func NewSpawnTool(hub *chat.Hub, run SubagentFunc) *SpawnTool {
+	return &SpawnTool{hub: hub, run: run, agents: make(map[string]*subagent)}
+}
 
-func (t *SpawnTool) Name() string        { return "spawn" }
-func (t *SpawnTool) Description() string { return "Spawn a background subagent (stub)" }
+func (t *SpawnTool) Name() string { return "spawn" }
+func (t *SpawnTool) Description() string {
+	return "Run a task in a background subagent that works on its own and reports back to this chat when done. Actions: spawn (start), list (show this chat's subagents), status (progress or result by id), cancel (stop by id)."
+}
 
 func (t *SpawnTool) Parameters() map[string]interface{} {
 	return map[string]interface{}{
 		"type": "object",
 		"properties": map[string]interface{}{
-			"agent": map[string]interface{}{
+			"action": map[string]interface{}{
 				"type":        "string",
-				"description": "The name of the agent to spawn",
+				"description": "The action: spawn (start a subagent), list (show this chat's subagents), status (show one subagent), cancel (stop one subagent). Defaults to spawn.",
+				"enum":        []string{"spawn", "list", "status", "cancel"},
 			},
 			"task": map[string]interface{}{
 				"type":        "string",
-				"description": "The task description for the spawned agent",
+				"description": "For spawn: a complete, self-contained description of the task. The subagent cannot see this conversation or ask questions.",
+			},
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "For spawn: a short name for the subagent, shown in its report",
+			},
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "For status and cancel: the subagent id returned by spawn",
 			},
 		},
 		"required": []string{},
@@ -33,11 +86,188 @@ func (t *SpawnTool) Parameters() map[string]interface{} {
 }
 
 func (t *SpawnTool) Execute(ctx context.Context, args map[string]interface{}) (string, error) {
-	agentName, _ := args["agent"].(string)
-	task, _ := args["task"].(string)
-	if agentName == "" && task == "" {
-		return "", fmt.Errorf("spawn: 'agent' or 'task' required")
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "spawn":
+		task, _ := args["task"].(string)
+		name, _ := args["name"].(string)
+		return t.spawn(ctx, name, strings.TrimSpace(task))
+	case "list":
+		return t.list(ctx), nil
+	case "status":
+		id, _ := args["id"].(string)
+		return t.status(ctx, id)
+	case "cancel":
+		id, _ := args["id"].(string)
+		return t.cancel(ctx, id)
+	default:
+		return "", fmt.Errorf("spawn: unknown action %q (use spawn, list, status, or cancel)", action)
+	}
+}
+
+func (t *SpawnTool) spawn(ctx context.Context, name, task string) (string, error) {
+	if task == "" {
+		return "", fmt.Errorf("spawn: 'task' is required")
+	}
+	if t.run == nil {
+		return "", fmt.Errorf("spawn: subagents are not available")
+	}
+	inv, ok := InvocationFrom(ctx)
+	if !ok || inv.Channel == "" {
+		return "", fmt.Errorf("spawn: no current chat to report back to")
+	}
+	if name == "" {
+		name = "subagent"
+	}
+
+	t.mu.Lock()
+	running := 0
+	for _, s := range t.agents {
+		if s.status == "running" {
+			running++
+		}
+	}
+	if running >= maxRunningSubagents {
+		t.mu.Unlock()
+		return "", fmt.Errorf("spawn: %d subagents are already running; wait for one to finish or cancel one", running)
+	}
+	t.nextID++
+	// The subagent outlives the turn that spawned it, so it must not inherit
+	// the turn's cancellation; it keeps the invocation values.
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s := &subagent{
+		id:      fmt.Sprintf("sub-%d", t.nextID),
+		name:    name,
+		task:    task,
+		channel: inv.Channel,
+		chatID:  inv.ChatID,
+		status:  "running",
+		started: time.Now(),
+		cancel:  cancel,
+	}
+	t.agents[s.id] = s
+	t.mu.Unlock()
+
+	log.Printf("spawn: started %s (%s) for %s:%s", s.id, name, s.channel, s.chatID)
+	go t.wait(runCtx, s)
+	return fmt.Sprintf("Started subagent %q (id: %s). It runs in the background and its report will be posted to this chat when it finishes.", name, s.id), nil
+}
+
+// wait runs the subagent and posts its result to the originating chat.
+func (t *SpawnTool) wait(ctx context.Context, s *subagent) {
+	result, err := t.run(ctx, s.task)
+	s.cancel()
+
+	t.mu.Lock()
+	switch {
+	case s.status == "canceled":
+		// canceled by the user: already reported by the cancel action
+	case err != nil:
+		s.status = "failed"
+		s.result = err.Error()
+	default:
+		s.status = "done"
+		s.result = result
+	}
+	s.finished = time.Now()
+	status, content := s.status, s.result
+	t.prune()
+	t.mu.Unlock()
+
+	log.Printf("spawn: %s finished with status %s", s.id, status)
+	var msg string
+	switch status {
+	case "done":
+		msg = fmt.Sprintf("Subagent %q (%s) finished:\n\n%s", s.name, s.id, content)
+	case "failed":
+		msg = fmt.Sprintf("Subagent %q (%s) failed: %s", s.name, s.id, content)
+	default:
+		return
+	}
+	select {
+	case t.hub.Out <- chat.Outbound{Channel: s.channel, ChatID: s.chatID, Content: msg}:
+	default:
+		log.Printf("spawn: outbound channel full, dropping report from %s", s.id)
+	}
+}
+
+// prune drops the oldest finished subagents beyond keepFinishedSubagents.
+// The caller must hold t.mu.
+func (t *SpawnTool) prune() {
+	var finished []*subagent
+	for _, s := range t.agents {
+		if s.status != "running" {
+			finished = append(finished, s)
+		}
+	}
+	if len(finished) <= keepFinishedSubagents {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].finished.Before(finished[j].finished) })
+	for _, s := range finished[:len(finished)-keepFinishedSubagents] {
+		delete(t.agents, s.id)
+	}
+}
+
+// ownedBy reports whether s was spawned from the chat in ctx. Subagents are
+// only visible to, and can only be canceled from, the chat that spawned them.
+func (s *subagent) ownedBy(ctx context.Context) bool {
+	inv, ok := InvocationFrom(ctx)
+	return ok && inv.Channel == s.channel && inv.ChatID == s.chatID
+}
+
+func (t *SpawnTool) list(ctx context.Context) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var all []*subagent
+	for _, s := range t.agents {
+		if s.ownedBy(ctx) {
+			all = append(all, s)
+		}
+	}
+	if len(all) == 0 {
+		return "No subagents."
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].started.Before(all[j].started) })
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d subagent(s):\n", len(all)))
+	for _, s := range all {
+		sb.WriteString(fmt.Sprintf("- %s (%s): %s, started %s ago\n", s.name, s.id, s.status, time.Since(s.started).Round(time.Second)))
+	}
+	return sb.String()
+}
+
+func (t *SpawnTool) status(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("spawn status: 'id' is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.agents[id]
+	if !ok || !s.ownedBy(ctx) {
+		return "", fmt.Errorf("spawn status: no subagent with id %q", id)
+	}
+	out := fmt.Sprintf("Subagent %q (%s): %s\nTask: %s", s.name, s.id, s.status, s.task)
+	if s.result != "" {
+		out += "\nResult:\n" + s.result
+	}
+	return out, nil
+}
+
+func (t *SpawnTool) cancel(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("spawn cancel: 'id' is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.agents[id]
+	if !ok || !s.ownedBy(ctx) {
+		return "", fmt.Errorf("spawn cancel: no subagent with id %q", id)
+	}
+	if s.status != "running" {
+		return fmt.Sprintf("Subagent %q (%s) already %s.", s.name, s.id, s.status), nil
 	}
-	// For v0 we simply return an acknowledgement
-	return fmt.Sprintf("spawned: agent=%s task=%s", agentName, task), nil
+	s.status = "canceled"
+	s.cancel()
+	return fmt.Sprintf("Canceled subagent %q (%s).", s.name, s.id), nil
 }
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/chat"
+)
+
+func TestSpawnToolReportsResultToOriginatingChat(t *testing.T) {
+	b := chat.NewHub(10)
+	st := NewSpawnTool(b, func(ctx context.Context, task string) (string, error) {
+		return "report for " + task, nil
+	})
+	ctx := WithInvocation(context.Background(), InvocationContext{Channel: "telegram", ChatID: "7"})
+
+	res, err := st.Execute(ctx, map[string]interface{}{"task": "research X", "name": "research"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(res, "sub-1") {
+		t.Fatalf("expected the subagent id in %q", res)
+	}
+
+	select {
+	case out := <-b.Out:
+		if out.Channel != "telegram" || out.ChatID != "7" || !strings.Contains(out.Content, "report for research X") {
+			t.Fatalf("unexpected report: %+v", out)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for subagent report")
+	}
+
+	status, err := st.Execute(ctx, map[string]interface{}{"action": "status", "id": "sub-1"})
+	if err != nil || !strings.Contains(status, "done") || !strings.Contains(status, "report for research X") {
+		t.Fatalf("unexpected status %q (err %v)", status, err)
+	}
+}
+
+func TestSpawnToolCancel(t *testing.T) {
+	b := chat.NewHub(10)
+	started := make(chan struct{})
+	stopped := make(chan error, 1)
+	st := NewSpawnTool(b, func(ctx context.Context, task string) (string, error) {
+		close(started)
+		<-ctx.Done()
+		stopped <- ctx.Err()
+		return "", ctx.Err()
+	})
+	// the spawning turn's context ends with the turn; the subagent must survive it
+	turnCtx, endTurn := context.WithCancel(context.Background())
+	turnCtx = WithInvocation(turnCtx, InvocationContext{Channel: "cli", ChatID: "1"})
+
+	if _, err := st.Execute(turnCtx, map[string]interface{}{"task": "wait forever"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	<-started
+	endTurn()
+
+	chatCtx := WithInvocation(context.Background(), InvocationContext{Channel: "cli", ChatID: "1"})
+	list, _ := st.Execute(chatCtx, map[string]interface{}{"action": "list"})
+	if !strings.Contains(list, "sub-1") || !strings.Contains(list, "running") {
+		t.Fatalf("expected a running subagent in %q", list)
+	}
+
+	if _, err := st.Execute(chatCtx, map[string]interface{}{"action": "cancel", "id": "sub-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case err := <-stopped:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected cancellation, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subagent was not canceled")
+	}
+	status, _ := st.Execute(chatCtx, map[string]interface{}{"action": "status", "id": "sub-1"})
+	if !strings.Contains(status, "canceled") {
+		t.Fatalf("expected canceled status, got %q", status)
+	}
+	select {
+	case out := <-b.Out:
+		t.Fatalf("expected no report for a canceled subagent, got %q", out.Content)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSpawnToolRequiresTaskAndChat(t *testing.T) {
+	st := NewSpawnTool(chat.NewHub(1), func(ctx context.Context, task string) (string, error) { return "", nil })
+	if _, err := st.Execute(context.Background(), map[string]interface{}{"task": "x"}); err == nil {
+		t.Fatal("expected an error without an invocation context")
+	}
+	ctx := WithInvocation(context.Background(), InvocationContext{Channel: "cli", ChatID: "1"})
+	if _, err := st.Execute(ctx, map[string]interface{}{}); err == nil {
+		t.Fatal("expected an error without a task")
+	}
+}
+
+func TestSpawnToolHidesOtherChatsSubagents(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	st := NewSpawnTool(chat.NewHub(10), func(ctx context.Context, task string) (string, error) {
+		<-release
+		return "", nil
+	})
+	mine := WithInvocation(context.Background(), InvocationContext{Channel: "telegram", ChatID: "1"})
+	if _, err := st.Execute(mine, map[string]interface{}{"task": "secret task"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	other := WithInvocation(context.Background(), InvocationContext{Channel: "telegram", ChatID: "2"})
+	if list, _ := st.Execute(other, map[string]interface{}{"action": "list"}); list != "No subagents." {
+		t.Fatalf("expected another chat to see no subagents, got %q", list)
+	}
+	for _, action := range []string{"status", "cancel"} {
+		_, err := st.Execute(other, map[string]interface{}{"action": action, "id": "sub-1"})
+		if err == nil || !strings.Contains(err.Error(), "no subagent with id") {
+			t.Fatalf("%s: expected another chat's subagent to be unknown, got %v", action, err)
+		}
+	}
+	if status, _ := st.Execute(mine, map[string]interface{}{"action": "status", "id": "sub-1"}); !strings.Contains(status, "running") {
+		t.Fatalf("expected the spawning chat to see its subagent, got %q", status)
+	}
+}
@@ -17,6 +17,9 @@ func NewWebTool() *WebTool { return &WebTool{} }
 func (t *WebTool) Name() string        { return "web" }
 func (t *WebTool) Description() string { return "Fetch web content from a URL" }
 
+// ParallelSafe reports that fetches can run concurrently.
+func (t *WebTool) ParallelSafe(args map[string]interface{}) bool { return true }
+
 func (t *WebTool) Parameters() map[string]interface{} {
 	return map[string]interface{}{
 		"type": "object",
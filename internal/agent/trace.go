@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/local/picobot/internal/agent/tools"
+	"github.com/local/picobot/internal/providers"
+	"github.com/local/picobot/internal/trace"
+)
+
+// SetTracer enables recording each turn's events (see package trace). A nil
+// tracer disables it.
+func (a *AgentLoop) SetTracer(t *trace.Tracer) {
+	a.tracer = t
+}
+
+// trace records e for the turn in ctx, if tracing is enabled.
+func (a *AgentLoop) trace(ctx context.Context, e trace.Event) {
+	if a.tracer == nil {
+		return
+	}
+	inv, _ := tools.InvocationFrom(ctx)
+	e.TurnID, e.Channel, e.ChatID = inv.TurnID, inv.Channel, inv.ChatID
+	if err := a.tracer.Record(e); err != nil {
+		log.Printf("trace: failed to record: %v", err)
+	}
+}
+
+// traceContext records the size of a freshly built prompt.
+func (a *AgentLoop) traceContext(ctx context.Context, messages []providers.Message) {
+	if a.tracer == nil {
+		return
+	}
+	a.trace(ctx, trace.Event{Kind: trace.KindContext, Messages: len(messages), EstTokens: estimateMessagesTokens(messages)})
+}
+
+// traceProvider records a model call that started at start, under the model
+// that served it.
+func (a *AgentLoop) traceProvider(ctx context.Context, model string, start time.Time, resp providers.LLMResponse, err error) {
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	e := trace.Event{
+		Time:             start,
+		Kind:             trace.KindProvider,
+		Model:            model,
+		DurationMs:       time.Since(start).Milliseconds(),
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		ToolCalls:        len(resp.ToolCalls),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	a.trace(ctx, e)
+}
+
+// executeTool runs one tool call from reg and records it.
+func (a *AgentLoop) executeTool(ctx context.Context, reg *tools.Registry, tc providers.ToolCall) (string, error) {
+	start := time.Now()
+	res, err := reg.Execute(ctx, tc.Name, tc.Arguments)
+	if a.tracer != nil {
+		args, _ := json.Marshal(tc.Arguments)
+		e := trace.Event{Time: start, Kind: trace.KindTool, Tool: tc.Name, Args: trace.Truncate(string(args)), DurationMs: time.Since(start).Milliseconds()}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		a.trace(ctx, e)
+	}
+	return res, err
+}
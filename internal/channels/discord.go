@@ -16,12 +16,14 @@ import (
 // It exists to enable testing without a live Discord WebSocket connection.
 type discordSender interface {
 	ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelTyping(channelID string, options ...discordgo.RequestOption) error
 }
 
 // StartDiscord starts a Discord bot using the discordgo library.
 // allowFrom restricts which Discord user IDs may send messages; empty means allow all.
-func StartDiscord(ctx context.Context, hub *chat.Hub, token string, allowFrom []string) error {
+// commands, if any, are registered as global slash commands.
+func StartDiscord(ctx context.Context, hub *chat.Hub, token string, allowFrom []string, commands []chat.Command) error {
 	if token == "" {
 		return fmt.Errorf("discord token not provided")
 	}
@@ -49,6 +51,12 @@ func StartDiscord(ctx context.Context, hub *chat.Hub, token string, allowFrom []
 
 	client := newDiscordClient(ctx, session, hub, botUser.ID, allowFrom)
 	session.AddHandler(client.handleMessage)
+	if len(commands) > 0 {
+		if _, err := session.ApplicationCommandBulkOverwrite(botUser.ID, "", discordApplicationCommands(commands)); err != nil {
+			log.Printf("discord: failed to register commands: %v", err)
+		}
+		session.AddHandler(client.handleInteraction)
+	}
 	go client.runOutbound()
 	go func() {
 		<-ctx.Done()
@@ -70,6 +78,9 @@ type discordClient struct {
 	ctx        context.Context
 	typingMu   sync.Mutex
 	typingStop map[string]chan struct{}
+	// live maps channel ID -> the streamed reply still being edited there.
+	// Only touched by runOutbound.
+	live map[string]discordLive
 }
 
 // newDiscordClient constructs a discordClient and registers it as the hub's
@@ -87,6 +98,7 @@ func newDiscordClient(ctx context.Context, sender discordSender, hub *chat.Hub,
 		allowed:    allowed,
 		ctx:        ctx,
 		typingStop: make(map[string]chan struct{}),
+		live:       make(map[string]discordLive),
 	}
 }
 
@@ -133,12 +145,18 @@ func (c *discordClient) handleMessage(_ *discordgo.Session, m *discordgo.Message
 	}
 	content = strings.TrimSpace(content)
 
-	// Append file attachment URLs as inline references.
+	// Image attachments are passed to the model as media; other files are
+	// appended as inline references.
+	var media []string
 	for _, att := range m.Attachments {
+		if strings.HasPrefix(att.ContentType, "image/") {
+			media = append(media, att.URL)
+			continue
+		}
 		content += fmt.Sprintf("\n[attachment: %s]", att.URL)
 	}
 
-	if content == "" {
+	if content == "" && len(media) == 0 {
 		return
 	}
 
@@ -153,6 +171,7 @@ func (c *discordClient) handleMessage(_ *discordgo.Session, m *discordgo.Message
 		ChatID:    m.ChannelID,
 		Content:   content,
 		Timestamp: time.Now(),
+		Media:     media,
 		Metadata: map[string]interface{}{
 			"username":   senderName,
 			"guild_id":   m.GuildID,
@@ -162,6 +181,84 @@ func (c *discordClient) handleMessage(_ *discordgo.Session, m *discordgo.Message
 	}
 }
 
+// discordApplicationCommands converts commands to Discord slash commands. A
+// command's argument becomes an optional "args" string option.
+func discordApplicationCommands(commands []chat.Command) []*discordgo.ApplicationCommand {
+	out := make([]*discordgo.ApplicationCommand, 0, len(commands))
+	for _, c := range commands {
+		ac := &discordgo.ApplicationCommand{Name: c.Name, Description: c.Description}
+		if c.Args != "" {
+			ac.Options = []*discordgo.ApplicationCommandOption{{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "args",
+				Description: c.Args,
+			}}
+		}
+		out = append(out, ac)
+	}
+	return out
+}
+
+// handleInteraction is the discordgo InteractionCreate event handler. Slash
+// commands are acknowledged with an echo of the command and forwarded to the
+// hub as "/name args" text; the agent's reply arrives as a normal message.
+func (c *discordClient) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	msg, ok := c.interactionInbound(i)
+	if !ok {
+		return
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: msg.Content},
+	})
+	if err != nil {
+		log.Printf("discord: interaction respond error: %v", err)
+	}
+	c.startTyping(msg.ChatID)
+	c.hub.In <- msg
+}
+
+// interactionInbound converts a slash command interaction to an inbound
+// message, applying the allowlist.
+func (c *discordClient) interactionInbound(i *discordgo.InteractionCreate) (chat.Inbound, bool) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return chat.Inbound{}, false
+	}
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return chat.Inbound{}, false
+	}
+	if len(c.allowed) > 0 {
+		if _, ok := c.allowed[user.ID]; !ok {
+			log.Printf("discord: dropped command from unauthorised user %s (%s)", user.Username, user.ID)
+			return chat.Inbound{}, false
+		}
+	}
+	data := i.ApplicationCommandData()
+	content := "/" + data.Name
+	for _, opt := range data.Options {
+		if opt.Name == "args" && opt.Type == discordgo.ApplicationCommandOptionString {
+			content += " " + opt.StringValue()
+		}
+	}
+	return chat.Inbound{
+		Channel:   "discord",
+		SenderID:  user.ID,
+		ChatID:    i.ChannelID,
+		Content:   content,
+		Timestamp: time.Now(),
+		Metadata: map[string]interface{}{
+			"username":   senderDisplayName(user),
+			"guild_id":   i.GuildID,
+			"channel_id": i.ChannelID,
+			"is_dm":      i.GuildID == "",
+		},
+	}, true
+}
+
 // runOutbound reads replies from the hub's discord subscription and sends them.
 func (c *discordClient) runOutbound() {
 	for {
@@ -169,8 +266,23 @@ func (c *discordClient) runOutbound() {
 		case <-c.ctx.Done():
 			return
 		case out := <-c.outCh:
+			if out.Partial {
+				c.sendPartial(out)
+				continue
+			}
 			c.stopTyping(out.ChatID)
-			for _, chunk := range splitMessage(out.Content, 2000) {
+			chunks := splitMessage(out.Content, 2000)
+			// Finish a streamed reply by editing the live message with the first
+			// chunk. Other messages (approval prompts, subagent reports) leave it be.
+			if cur, ok := c.live[out.ChatID]; ok && cur.stream == out.StreamID {
+				delete(c.live, out.ChatID)
+				if _, err := c.sender.ChannelMessageEdit(out.ChatID, cur.msgID, chunks[0]); err == nil {
+					chunks = chunks[1:]
+				} else {
+					log.Printf("discord: edit error: %v", err)
+				}
+			}
+			for _, chunk := range chunks {
 				if _, err := c.sender.ChannelMessageSend(out.ChatID, chunk); err != nil {
 					log.Printf("discord: send error: %v", err)
 				}
@@ -179,6 +291,33 @@ func (c *discordClient) runOutbound() {
 	}
 }
 
+// sendPartial creates or updates the live message for a streamed reply.
+// Previews longer than one Discord message are skipped until the final reply.
+func (c *discordClient) sendPartial(out chat.Outbound) {
+	if len([]rune(out.Content)) > 2000 || strings.TrimSpace(out.Content) == "" {
+		return
+	}
+	cur, ok := c.live[out.ChatID]
+	if !ok || cur.stream != out.StreamID {
+		m, err := c.sender.ChannelMessageSend(out.ChatID, out.Content)
+		if err != nil {
+			log.Printf("discord: send error: %v", err)
+			return
+		}
+		c.live[out.ChatID] = discordLive{stream: out.StreamID, msgID: m.ID}
+		return
+	}
+	if _, err := c.sender.ChannelMessageEdit(out.ChatID, cur.msgID, out.Content); err != nil {
+		log.Printf("discord: edit error: %v", err)
+	}
+}
+
+// discordLive is a streamed reply that is being edited in place.
+type discordLive struct {
+	stream string // chat.Outbound.StreamID
+	msgID  string
+}
+
 // startTyping begins (or resets) a continuous typing indicator for a channel.
 // It stops automatically after 5 minutes or when stopTyping / stopAllTyping is called.
 func (c *discordClient) startTyping(channelID string) {
@@ -5,9 +5,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/local/picobot/internal/chat"
 )
 
@@ -105,7 +107,7 @@ func mockDiscordServer() *httptest.Server {
 // TestStartDiscord_EmptyToken tests that StartDiscord returns an error with empty token.
 func TestStartDiscord_EmptyToken(t *testing.T) {
 	hub := chat.NewHub(100)
-	err := StartDiscord(context.Background(), hub, "", nil)
+	err := StartDiscord(context.Background(), hub, "", nil, nil)
 	if err == nil {
 		t.Error("StartDiscord with empty token should return error")
 	}
@@ -329,3 +331,109 @@ func TestDiscordClient_NewlineSplit(t *testing.T) {
 		t.Error("second chunk should start with 'b'")
 	}
 }
+
+// recordingSender is a discordSender that records sends and edits.
+type recordingSender struct {
+	mu    sync.Mutex
+	sent  []string
+	edits []string
+}
+
+func (r *recordingSender) ChannelMessageSend(channelID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, content)
+	return &discordgo.Message{ID: "m1", ChannelID: channelID, Content: content}, nil
+}
+
+func (r *recordingSender) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.edits = append(r.edits, messageID+":"+content)
+	return &discordgo.Message{ID: messageID, ChannelID: channelID, Content: content}, nil
+}
+
+func (r *recordingSender) ChannelTyping(channelID string, options ...discordgo.RequestOption) error {
+	return nil
+}
+
+// TestDiscordClient_StreamedReplyEditsOneMessage checks that partial replies
+// create one message that is then edited in place by later partials and the
+// final reply, while other messages to the channel are sent separately.
+func TestDiscordClient_StreamedReplyEditsOneMessage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hub := chat.NewHub(10)
+	sender := &recordingSender{}
+	c := newDiscordClient(ctx, sender, hub, "bot", nil)
+	hub.StartRouter(ctx)
+	go c.runOutbound()
+
+	hub.Out <- chat.Outbound{Channel: "discord", ChatID: "c1", Content: "Hel", Partial: true, StreamID: "t1"}
+	hub.Out <- chat.Outbound{Channel: "discord", ChatID: "c1", Content: "Approve?"}
+	hub.Out <- chat.Outbound{Channel: "discord", ChatID: "c1", Content: "Hello", Partial: true, StreamID: "t1"}
+	hub.Out <- chat.Outbound{Channel: "discord", ChatID: "c1", Content: "Hello, world", StreamID: "t1"}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sender.mu.Lock()
+		done := len(sender.edits) == 2
+		sender.mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.sent) != 2 || sender.sent[0] != "Hel" || sender.sent[1] != "Approve?" {
+		t.Fatalf("expected the live message and the unrelated one, got %v", sender.sent)
+	}
+	if len(sender.edits) != 2 || sender.edits[1] != "m1:Hello, world" {
+		t.Fatalf("expected final reply to edit the live message, got %v", sender.edits)
+	}
+}
+
+func TestDiscordApplicationCommands(t *testing.T) {
+	acs := discordApplicationCommands([]chat.Command{
+		{Name: "reset", Description: "Start fresh"},
+		{Name: "model", Description: "Switch model", Args: "<name>"},
+	})
+	if len(acs) != 2 || len(acs[0].Options) != 0 {
+		t.Fatalf("unexpected commands: %+v", acs)
+	}
+	if len(acs[1].Options) != 1 || acs[1].Options[0].Name != "args" || acs[1].Options[0].Required {
+		t.Fatalf("expected an optional args option, got %+v", acs[1].Options)
+	}
+}
+
+func TestDiscordClient_InteractionInbound(t *testing.T) {
+	hub := chat.NewHub(10)
+	c := newDiscordClient(context.Background(), &recordingSender{}, hub, "bot", []string{"u1"})
+	interaction := func(userID string) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: "c1",
+			GuildID:   "g1",
+			Member:    &discordgo.Member{User: &discordgo.User{ID: userID, Username: "alice"}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: "model",
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Name: "args", Type: discordgo.ApplicationCommandOptionString, Value: "gpt-4o"},
+				},
+			},
+		}}
+	}
+
+	msg, ok := c.interactionInbound(interaction("u1"))
+	if !ok {
+		t.Fatal("expected the command to be accepted")
+	}
+	if msg.Content != "/model gpt-4o" || msg.ChatID != "c1" || msg.SenderID != "u1" {
+		t.Fatalf("unexpected inbound: %+v", msg)
+	}
+	if _, ok := c.interactionInbound(interaction("u2")); ok {
+		t.Fatal("expected a command from a user outside the allowlist to be dropped")
+	}
+}
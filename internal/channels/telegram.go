@@ -2,6 +2,7 @@ package channels
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/local/picobot/internal/chat"
@@ -18,17 +20,18 @@ import (
 // with the standard Telegram base URL.
 // allowFrom is a list of Telegram user IDs permitted to interact with the bot.
 // If empty, ALL users are allowed (open mode).
-func StartTelegram(ctx context.Context, hub *chat.Hub, token string, allowFrom []string) error {
+// commands, if any, are registered as the bot's command menu.
+func StartTelegram(ctx context.Context, hub *chat.Hub, token string, allowFrom []string, commands []chat.Command) error {
 	if token == "" {
 		return fmt.Errorf("telegram token not provided")
 	}
 	base := "https://api.telegram.org/bot" + token
-	return StartTelegramWithBase(ctx, hub, token, base, allowFrom)
+	return StartTelegramWithBase(ctx, hub, token, base, allowFrom, commands)
 }
 
 // StartTelegramWithBase starts long-polling against the given base URL (e.g., https://api.telegram.org/bot<TOKEN> or a test server URL).
 // allowFrom restricts which Telegram user IDs may send messages. Empty means allow all.
-func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base string, allowFrom []string) error {
+func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base string, allowFrom []string, commands []chat.Command) error {
 	if base == "" {
 		return fmt.Errorf("base URL is required")
 	}
@@ -41,6 +44,12 @@ func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base strin
 
 	client := &http.Client{Timeout: 45 * time.Second}
 
+	if len(commands) > 0 {
+		if err := telegramSetCommands(client, base, commands); err != nil {
+			log.Printf("telegram: failed to register commands: %v", err)
+		}
+	}
+
 	// inbound polling goroutine
 	go func() {
 		offset := int64(0)
@@ -76,7 +85,16 @@ func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base strin
 						Chat struct {
 							ID int64 `json:"id"`
 						} `json:"chat"`
-						Text string `json:"text"`
+						Text    string `json:"text"`
+						Caption string `json:"caption"`
+						// Photo lists the sizes of one photo, smallest first.
+						Photo []struct {
+							FileID string `json:"file_id"`
+						} `json:"photo"`
+						Document *struct {
+							FileID   string `json:"file_id"`
+							MimeType string `json:"mime_type"`
+						} `json:"document"`
 					} `json:"message"`
 				} `json:"result"`
 			}
@@ -104,12 +122,33 @@ func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base strin
 					}
 				}
 				chatID := strconv.FormatInt(m.Chat.ID, 10)
+				content := m.Text
+				if content == "" {
+					content = m.Caption
+				}
+				// Images are downloaded and inlined as data URLs: Telegram file
+				// URLs embed the bot token and must not be handed to a provider.
+				var imageFileID string
+				if len(m.Photo) > 0 {
+					imageFileID = m.Photo[len(m.Photo)-1].FileID
+				} else if m.Document != nil && strings.HasPrefix(m.Document.MimeType, "image/") {
+					imageFileID = m.Document.FileID
+				}
+				var media []string
+				if imageFileID != "" {
+					if dataURL, err := telegramDownloadImage(client, base, imageFileID); err != nil {
+						log.Printf("telegram: failed to download image: %v", err)
+					} else {
+						media = append(media, dataURL)
+					}
+				}
 				hub.In <- chat.Inbound{
 					Channel:   "telegram",
 					SenderID:  fromID,
 					ChatID:    chatID,
-					Content:   m.Text,
+					Content:   content,
 					Timestamp: time.Now(),
+					Media:     media,
 				}
 			}
 		}
@@ -122,26 +161,202 @@ func StartTelegramWithBase(ctx context.Context, hub *chat.Hub, token, base strin
 	// outbound sender goroutine
 	go func() {
 		client := &http.Client{Timeout: 10 * time.Second}
+		// live maps chat ID -> the streamed reply still being edited there.
+		live := make(map[string]telegramLive)
 		for {
 			select {
 			case <-ctx.Done():
 				log.Println("telegram: stopping outbound sender")
 				return
 			case out := <-outCh:
-				u := base + "/sendMessage"
-				v := url.Values{}
-				v.Set("chat_id", out.ChatID)
-				v.Set("text", out.Content)
-				resp, err := client.PostForm(u, v)
-				if err != nil {
-					log.Printf("telegram sendMessage error: %v", err)
+				cur, ok := live[out.ChatID]
+				streaming := ok && cur.stream == out.StreamID
+				if out.Partial {
+					if len([]rune(out.Content)) > telegramMaxMessageLen {
+						continue // too long to preview; wait for the final reply
+					}
+					if !streaming {
+						if id, err := telegramSend(client, base, out.ChatID, out.Content); err == nil {
+							live[out.ChatID] = telegramLive{stream: out.StreamID, msgID: id}
+						}
+						continue
+					}
+					telegramEdit(client, base, out.ChatID, cur.msgID, out.Content)
+					continue
+				}
+				// Only the final message of the same stream finishes it; anything
+				// else (an approval prompt, a subagent report) is sent on its own.
+				if !streaming {
+					telegramSend(client, base, out.ChatID, out.Content)
+					continue
+				}
+				delete(live, out.ChatID)
+				// Finish a streamed reply in place; fall back to a new message if the
+				// edit fails (e.g. the final text exceeds Telegram's length limit).
+				if telegramEdit(client, base, out.ChatID, cur.msgID, out.Content) == nil {
 					continue
 				}
-				io.ReadAll(resp.Body)
-				resp.Body.Close()
+				telegramSend(client, base, out.ChatID, out.Content)
 			}
 		}
 	}()
 
 	return nil
 }
+
+// telegramLive is a streamed reply that is being edited in place.
+type telegramLive struct {
+	stream string // chat.Outbound.StreamID
+	msgID  int64
+}
+
+// telegramMaxMessageLen is Telegram's limit on message text length.
+const telegramMaxMessageLen = 4096
+
+// telegramSend posts a new message and returns its message ID.
+func telegramSend(client *http.Client, base, chatID, text string) (int64, error) {
+	v := url.Values{}
+	v.Set("chat_id", chatID)
+	v.Set("text", text)
+	resp, err := client.PostForm(base+"/sendMessage", v)
+	if err != nil {
+		log.Printf("telegram sendMessage error: %v", err)
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var sr struct {
+		Ok     bool `json:"ok"`
+		Result struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &sr); err != nil || !sr.Ok {
+		return 0, fmt.Errorf("telegram sendMessage failed: %s", body)
+	}
+	return sr.Result.MessageID, nil
+}
+
+// telegramEdit replaces the text of a previously sent message.
+func telegramEdit(client *http.Client, base, chatID string, messageID int64, text string) error {
+	v := url.Values{}
+	v.Set("chat_id", chatID)
+	v.Set("message_id", strconv.FormatInt(messageID, 10))
+	v.Set("text", text)
+	resp, err := client.PostForm(base+"/editMessageText", v)
+	if err != nil {
+		log.Printf("telegram editMessageText error: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+	var er struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &er); err != nil {
+		return err
+	}
+	// Editing with identical text is reported as an error but is harmless.
+	if !er.Ok && !strings.Contains(er.Description, "message is not modified") {
+		return fmt.Errorf("telegram editMessageText failed: %s", er.Description)
+	}
+	return nil
+}
+
+// telegramSetCommands registers commands as the bot's command menu, shown when
+// the user types "/".
+func telegramSetCommands(client *http.Client, base string, commands []chat.Command) error {
+	type botCommand struct {
+		Command     string `json:"command"`
+		Description string `json:"description"`
+	}
+	list := make([]botCommand, 0, len(commands))
+	for _, c := range commands {
+		desc := c.Description
+		if c.Args != "" {
+			desc += " (" + c.Args + ")"
+		}
+		list = append(list, botCommand{Command: c.Name, Description: desc})
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("commands", string(b))
+	resp, err := client.PostForm(base+"/setMyCommands", v)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var sr struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &sr); err != nil || !sr.Ok {
+		return fmt.Errorf("telegram setMyCommands failed: %s", body)
+	}
+	return nil
+}
+
+// telegramMaxImageBytes caps downloaded images; larger files are rejected by
+// most vision APIs anyway.
+const telegramMaxImageBytes = 5 << 20
+
+// telegramDownloadImage resolves a file ID with getFile, downloads the file and
+// returns it as a base64 data URL.
+func telegramDownloadImage(client *http.Client, base, fileID string) (string, error) {
+	v := url.Values{}
+	v.Set("file_id", fileID)
+	resp, err := client.PostForm(base+"/getFile", v)
+	if err != nil {
+		return "", err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var gf struct {
+		Ok     bool `json:"ok"`
+		Result struct {
+			FilePath string `json:"file_path"`
+			FileSize int64  `json:"file_size"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &gf); err != nil || !gf.Ok || gf.Result.FilePath == "" {
+		return "", fmt.Errorf("telegram getFile failed: %s", body)
+	}
+	if gf.Result.FileSize > telegramMaxImageBytes {
+		return "", fmt.Errorf("image too large (%d bytes)", gf.Result.FileSize)
+	}
+
+	resp, err = client.Get(telegramFileBase(base) + "/" + gf.Result.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("telegram file download failed: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, telegramMaxImageBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > telegramMaxImageBytes {
+		return "", fmt.Errorf("image too large (over %d bytes)", telegramMaxImageBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("downloaded file is not an image (%s)", mimeType)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// telegramFileBase maps the bot API base (".../bot<TOKEN>") to the file
+// download base (".../file/bot<TOKEN>").
+func telegramFileBase(base string) string {
+	if i := strings.LastIndex(base, "/bot"); i >= 0 {
+		return base[:i] + "/file" + base[i:]
+	}
+	return base + "/file"
+}
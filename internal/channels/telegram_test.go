@@ -2,6 +2,7 @@ package channels
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -47,7 +48,7 @@ func TestStartTelegramWithBase(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if err := StartTelegramWithBase(ctx, b, token, base, nil); err != nil {
+	if err := StartTelegramWithBase(ctx, b, token, base, nil, nil); err != nil {
 		t.Fatalf("StartTelegramWithBase failed: %v", err)
 	}
 	// Start the hub router so outbound messages sent to b.Out are dispatched
@@ -85,3 +86,137 @@ func TestStartTelegramWithBase(t *testing.T) {
 	// give a small grace period
 	time.Sleep(50 * time.Millisecond)
 }
+
+func TestTelegramStreamedReplyEditsMessage(t *testing.T) {
+	calls := make(chan string, 8)
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			r.ParseForm()
+			calls <- "send:" + r.PostForm.Get("text")
+			w.Write([]byte(`{"ok":true,"result":{"message_id":7}}`))
+		case strings.HasSuffix(r.URL.Path, "/editMessageText"):
+			r.ParseForm()
+			calls <- "edit:" + r.PostForm.Get("message_id") + ":" + r.PostForm.Get("text")
+			w.Write([]byte(`{"ok":true,"result":{}}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer h.Close()
+
+	b := chat.NewHub(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := StartTelegramWithBase(ctx, b, "tok", h.URL+"/bottok", nil, nil); err != nil {
+		t.Fatalf("StartTelegramWithBase failed: %v", err)
+	}
+	b.StartRouter(ctx)
+
+	b.Out <- chat.Outbound{Channel: "telegram", ChatID: "456", Content: "Hel", Partial: true, StreamID: "t1"}
+	// a message from outside the stream must not replace the reply in progress
+	b.Out <- chat.Outbound{Channel: "telegram", ChatID: "456", Content: "Approve?"}
+	b.Out <- chat.Outbound{Channel: "telegram", ChatID: "456", Content: "Hello", Partial: true, StreamID: "t1"}
+	b.Out <- chat.Outbound{Channel: "telegram", ChatID: "456", Content: "Hello, world", StreamID: "t1"}
+
+	want := []string{"send:Hel", "send:Approve?", "edit:7:Hello", "edit:7:Hello, world"}
+	for _, w := range want {
+		select {
+		case got := <-calls:
+			if got != w {
+				t.Fatalf("expected %q, got %q", w, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for %q", w)
+		}
+	}
+}
+
+func TestTelegramPhotoBecomesDataURL(t *testing.T) {
+	token := "testtoken"
+	png := []byte("\x89PNG\r\n\x1a\n0000")
+	first := true
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			if first {
+				first = false
+				w.Write([]byte(`{"ok":true,"result":[{"update_id":1,"message":{"message_id":1,"from":{"id":123},"chat":{"id":456},"caption":"what is this?","photo":[{"file_id":"small"},{"file_id":"large"}]}}]}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		case strings.HasSuffix(r.URL.Path, "/getFile"):
+			r.ParseForm()
+			if r.PostForm.Get("file_id") != "large" {
+				t.Errorf("expected the largest photo size, got %q", r.PostForm.Get("file_id"))
+			}
+			w.Write([]byte(`{"ok":true,"result":{"file_path":"photos/file_1.png","file_size":12}}`))
+		case r.URL.Path == "/file/bot"+token+"/photos/file_1.png":
+			w.Write(png)
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer h.Close()
+
+	b := chat.NewHub(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := StartTelegramWithBase(ctx, b, token, h.URL+"/bot"+token, nil, nil); err != nil {
+		t.Fatalf("StartTelegramWithBase failed: %v", err)
+	}
+
+	select {
+	case msg := <-b.In:
+		if msg.Content != "what is this?" {
+			t.Fatalf("expected caption as content, got %q", msg.Content)
+		}
+		if len(msg.Media) != 1 || !strings.HasPrefix(msg.Media[0], "data:image/png;base64,") {
+			t.Fatalf("expected one PNG data URL, got %v", msg.Media)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for inbound message")
+	}
+}
+
+func TestStartTelegramRegistersCommands(t *testing.T) {
+	got := make(chan string, 1)
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/setMyCommands"):
+			r.ParseForm()
+			got <- r.PostForm.Get("commands")
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		case strings.HasSuffix(r.URL.Path, "/getUpdates"):
+			w.Write([]byte(`{"ok":true,"result":[]}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer h.Close()
+
+	b := chat.NewHub(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmds := []chat.Command{{Name: "reset", Description: "Start fresh"}, {Name: "model", Description: "Switch model", Args: "<name>"}}
+	if err := StartTelegramWithBase(ctx, b, "tok", h.URL+"/bottok", nil, cmds); err != nil {
+		t.Fatalf("StartTelegramWithBase failed: %v", err)
+	}
+
+	select {
+	case v := <-got:
+		var list []struct{ Command, Description string }
+		if err := json.Unmarshal([]byte(v), &list); err != nil {
+			t.Fatalf("invalid commands JSON %q: %v", v, err)
+		}
+		if len(list) != 2 || list[0].Command != "reset" || list[1].Description != "Switch model (<name>)" {
+			t.Fatalf("unexpected commands: %s", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for setMyCommands")
+	}
+}
@@ -376,6 +376,9 @@ func (c *whatsappClient) runOutbound() {
 			log.Println("whatsapp: stopping outbound sender")
 			return
 		case out := <-c.outCh:
+			if out.Partial {
+				continue // WhatsApp cannot edit messages; wait for the final reply
+			}
 			recipient, err := types.ParseJID(out.ChatID)
 			if err != nil {
 				log.Printf("whatsapp: invalid chat ID %s: %v", out.ChatID, err)
@@ -14,11 +14,19 @@ type Inbound struct {
 	ChatID    string
 	Content   string
 	Timestamp time.Time
-	Media     []string
-	Metadata  map[string]interface{}
+	// Media holds images sent with the message, as http(s) URLs or base64 data URLs.
+	Media    []string
+	Metadata map[string]interface{}
 }
 
 // Outbound represents a message produced by the agent.
+//
+// When Partial is true the message is an in-progress streamed reply: Content
+// holds the full text generated so far, and channels that support editing
+// should update a single live message in place. The final reply arrives as a
+// normal (non-partial) Outbound with the same StreamID, and only it finishes
+// the live message; other messages to the chat in the meantime, such as
+// approval prompts, are sent separately.
 type Outbound struct {
 	Channel  string
 	ChatID   string
@@ -26,6 +34,8 @@ type Outbound struct {
 	ReplyTo  string
 	Media    []string
 	Metadata map[string]interface{}
+	Partial  bool
+	StreamID string // identifies the streamed reply; the agent uses the turn ID
 }
 
 // Hub provides simple buffered channels for inbound/outbound messages.
@@ -0,0 +1,11 @@
+package chat
+
+// Command describes an in-chat slash command (e.g. /reset) so that channels
+// can list it in their native command menus. Commands are handled by the
+// agent; channels only deliver them as ordinary inbound text.
+type Command struct {
+	Name        string // without the slash, e.g. "model"
+	Description string
+	// Args describes the command's argument, e.g. "<name>"; empty if it takes none.
+	Args string
+}
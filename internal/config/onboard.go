@@ -17,7 +17,6 @@ func DefaultConfig() Config {
 			Workspace:          "~/.picobot/workspace",
 			Model:              "stub-model",
 			MaxTokens:          8192,
-			Temperature:        0.7,
 			MaxToolIterations:  100,
 			HeartbeatIntervalS: 60,
 			RequestTimeoutS:    60,
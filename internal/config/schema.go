@@ -5,20 +5,70 @@ type Config struct {
 	Agents    AgentsConfig    `json:"agents"`
 	Channels  ChannelsConfig  `json:"channels"`
 	Providers ProvidersConfig `json:"providers"`
+	// Pricing maps model names to their price, used by `picobot usage` to estimate cost.
+	Pricing map[string]ModelPrice `json:"pricing,omitempty"`
+}
+
+// ModelPrice is the price in USD per million prompt (input) and completion (output) tokens.
+type ModelPrice struct {
+	InputPerMTok  float64 `json:"inputPerMTok"`
+	OutputPerMTok float64 `json:"outputPerMTok"`
 }
 
 type AgentsConfig struct {
 	Defaults AgentDefaults `json:"defaults"`
+	// Profiles are named model and generation settings, e.g. a cheap "fast"
+	// profile for heartbeat and a "strong" one for interactive chats.
+	Profiles map[string]ModelProfile `json:"profiles,omitempty"`
+	// ChannelProfiles maps a channel ("telegram", "discord", "whatsapp", "cli",
+	// "heartbeat", "cron") to the name of the profile used for its messages.
+	ChannelProfiles map[string]string `json:"channelProfiles,omitempty"`
+	// Approval lists tool calls that need the user's OK in chat before they run.
+	Approval []ApprovalRule `json:"approval,omitempty"`
+}
+
+// ApprovalRule marks calls to Tool as needing approval. Match and Except map
+// argument names to regular expressions: the rule applies when every Match
+// pattern matches and no Except pattern does. List arguments (exec's cmd) are
+// matched joined with spaces.
+type ApprovalRule struct {
+	Tool   string            `json:"tool"`
+	Match  map[string]string `json:"match,omitempty"`
+	Except map[string]string `json:"except,omitempty"`
+}
+
+// ModelProfile overrides the default model and generation settings. Unset
+// fields fall back to agents.defaults.
+type ModelProfile struct {
+	Model           string   `json:"model,omitempty"`
+	MaxTokens       int      `json:"maxTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	Stop            []string `json:"stop,omitempty"`
+	Seed            *int     `json:"seed,omitempty"`
+	ReasoningEffort string   `json:"reasoningEffort,omitempty"` // "low" | "medium" | "high"
 }
 
 type AgentDefaults struct {
-	Workspace          string  `json:"workspace"`
-	Model              string  `json:"model"`
-	MaxTokens          int     `json:"maxTokens"`
-	Temperature        float64 `json:"temperature"`
-	MaxToolIterations  int     `json:"maxToolIterations"`
-	HeartbeatIntervalS int     `json:"heartbeatIntervalS"`
-	RequestTimeoutS    int     `json:"requestTimeoutS"`
+	Workspace          string   `json:"workspace"`
+	Model              string   `json:"model"`
+	MaxTokens          int      `json:"maxTokens"`
+	Temperature        *float64 `json:"temperature,omitempty"` // nil leaves it to the provider
+	MaxToolIterations  int      `json:"maxToolIterations"`
+	HeartbeatIntervalS int      `json:"heartbeatIntervalS"`
+	RequestTimeoutS    int      `json:"requestTimeoutS"`
+	Streaming          bool     `json:"streaming,omitempty"`
+	Vision             bool     `json:"vision,omitempty"`
+	ContextWindow      int      `json:"contextWindow,omitempty"`
+	// ReasoningEffort ("low" | "medium" | "high") is sent to reasoning models
+	// instead of temperature.
+	ReasoningEffort string `json:"reasoningEffort,omitempty"`
+	// LogReasoning saves models' reasoning output to workspace/reasoning for debugging.
+	LogReasoning bool `json:"logReasoning,omitempty"`
+	// MaxConcurrency caps how many chats the gateway processes at once (default 4).
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// InterruptOnNewMessage makes a new message in a chat stop that chat's reply in progress.
+	InterruptOnNewMessage bool `json:"interruptOnNewMessage,omitempty"`
 }
 
 type ChannelsConfig struct {
@@ -46,10 +96,41 @@ type WhatsAppConfig struct {
 }
 
 type ProvidersConfig struct {
-	OpenAI *ProviderConfig `json:"openai,omitempty"`
+	OpenAI    *ProviderConfig `json:"openai,omitempty"`
+	Anthropic *ProviderConfig `json:"anthropic,omitempty"`
+	Ollama    *OllamaConfig   `json:"ollama,omitempty"`
+	Failover  *FailoverConfig `json:"failover,omitempty"`
+}
+
+// OllamaConfig configures the native Ollama provider. No API key is needed.
+type OllamaConfig struct {
+	APIBase   string `json:"apiBase"`             // e.g. http://localhost:11434
+	KeepAlive string `json:"keepAlive,omitempty"` // e.g. "10m", or "-1" to keep the model loaded
+	NumCtx    int    `json:"numCtx,omitempty"`    // context window in tokens; 0 = model default
+}
+
+// FailoverConfig declares an ordered chain of providers to try in turn.
+type FailoverConfig struct {
+	Chain            []FailoverTarget `json:"chain"`
+	FailureThreshold int              `json:"failureThreshold,omitempty"`
+	CooldownS        int              `json:"cooldownS,omitempty"`
+}
+
+// FailoverTarget is one link in the chain. APIKey/APIBase fall back to the
+// matching providers.<provider> entry when empty.
+type FailoverTarget struct {
+	Provider string `json:"provider"` // "openai" | "anthropic" | "ollama"
+	Model    string `json:"model,omitempty"`
+	APIKey   string `json:"apiKey,omitempty"`
+	APIBase  string `json:"apiBase,omitempty"`
 }
 
 type ProviderConfig struct {
 	APIKey  string `json:"apiKey"`
 	APIBase string `json:"apiBase"`
+	// MaxRetries for retryable errors (429, 5xx, network). 0 uses the default, -1 disables.
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// EmbeddingModel enables the /embeddings endpoint, e.g. "text-embedding-3-small".
+	// Only used for providers.openai.
+	EmbeddingModel string `json:"embeddingModel,omitempty"`
 }
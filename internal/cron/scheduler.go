@@ -17,6 +17,7 @@ type Job struct {
 	ChatID    string // originating chat ID
 	Recurring bool   // if true, re-schedule after firing
 	Interval  time.Duration
+	Profile   string // optional model profile (agents.profiles) to run the job with
 	fired     bool
 }
 
@@ -78,6 +79,17 @@ func (s *Scheduler) AddRecurring(name, message string, interval time.Duration, c
 	return id
 }
 
+// SetProfile sets the model profile a job runs with. Returns true if found.
+func (s *Scheduler) SetProfile(id, profile string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if ok {
+		j.Profile = profile
+	}
+	return ok
+}
+
 // Cancel removes a job by ID. Returns true if found.
 func (s *Scheduler) Cancel(id string) bool {
 	s.mu.Lock()
@@ -0,0 +1,282 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// anthropicVersion is the Messages API version sent with every request.
+const anthropicVersion = "2023-06-01"
+
+// anthropicDefaultMaxTokens is used when the caller does not specify a limit;
+// the Messages API requires max_tokens on every request.
+const anthropicDefaultMaxTokens = 4096
+
+// AnthropicProvider calls the Anthropic Messages API natively.
+// Unlike the OpenAI-compatible path it keeps system prompts at the top level
+// and maps tool calls to tool_use / tool_result content blocks.
+type AnthropicProvider struct {
+	APIKey  string
+	APIBase string // e.g. https://api.anthropic.com/v1
+	Client  *http.Client
+}
+
+func NewAnthropicProvider(apiKey, apiBase string, timeoutSecs int) *AnthropicProvider {
+	if apiBase == "" {
+		apiBase = "https://api.anthropic.com/v1"
+	}
+	if timeoutSecs <= 1 {
+		timeoutSecs = 60 // default 60 seconds
+	}
+	return &AnthropicProvider{
+		APIKey:  apiKey,
+		APIBase: strings.TrimRight(apiBase, "/"),
+		Client: &http.Client{
+			Timeout: time.Duration(timeoutSecs) * time.Second,
+		},
+	}
+}
+
+func (p *AnthropicProvider) GetDefaultModel() string { return "claude-sonnet-4-5" }
+
+// Request/response shapes for the Messages API.
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"` // "user" | "assistant"
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock is a content block; only the fields relevant to its Type are set.
+type anthropicBlock struct {
+	Type      string                `json:"type"` // "text" | "image" | "tool_use" | "tool_result" | "thinking"
+	Text      string                `json:"text,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   string                `json:"content,omitempty"`
+	Thinking  string                `json:"thinking,omitempty"`
+}
+
+// anthropicImageSource is either {"type":"base64","media_type","data"} or {"type":"url","url"}.
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// anthropicImageBlock converts an image URL or base64 data URL to an image block.
+func anthropicImageBlock(imageURL string) (anthropicBlock, bool) {
+	if rest, ok := strings.CutPrefix(imageURL, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+		if !found || !isBase64 {
+			return anthropicBlock{}, false
+		}
+		return anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}}, true
+	}
+	return anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "url", URL: imageURL}}, true
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Chat calls the Messages endpoint and returns a normalized response.
+// Seed, ReasoningEffort and ResponseFormat are not supported by the Messages
+// API and are ignored; ChatJSON asks for JSON in the prompt instead.
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	if p.APIKey == "" {
+		return LLMResponse{}, errors.New("Anthropic provider: API key is not configured")
+	}
+	if model == "" {
+		model = p.GetDefaultModel()
+	}
+
+	reqBody := anthropicRequest{
+		Model:         model,
+		MaxTokens:     opts.MaxTokens,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.Stop,
+	}
+	if reqBody.MaxTokens <= 0 {
+		reqBody.MaxTokens = anthropicDefaultMaxTokens
+	}
+	reqBody.System, reqBody.Messages = toAnthropicMessages(messages)
+
+	for _, t := range tools {
+		params := t.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		reqBody.Tools = append(reqBody.Tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: params})
+	}
+
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+
+	url := fmt.Sprintf("%s/messages", p.APIBase)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(b)))
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		body := strings.TrimSpace(string(bodyBytes))
+		log.Printf("Anthropic API non-2xx: %s body=%q", resp.Status, body)
+		return LLMResponse{}, &APIError{
+			Provider:   "Anthropic",
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+			RetryAfter: parseRetryAfter(resp.Header, time.Now()),
+		}
+	}
+
+	var out anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return LLMResponse{}, err
+	}
+
+	var text, reasoning strings.Builder
+	var tcs []ToolCall
+	for _, blk := range out.Content {
+		switch blk.Type {
+		case "text":
+			text.WriteString(blk.Text)
+		case "thinking":
+			reasoning.WriteString(blk.Thinking)
+		case "tool_use":
+			args := map[string]interface{}{}
+			if len(blk.Input) > 0 {
+				if err := json.Unmarshal(blk.Input, &args); err != nil {
+					// skip unparseable tool calls
+					continue
+				}
+			}
+			tcs = append(tcs, ToolCall{ID: blk.ID, Name: blk.Name, Arguments: args})
+		}
+	}
+
+	switch out.StopReason {
+	case "max_tokens":
+		log.Printf("Anthropic API: response truncated at max_tokens (%d)", reqBody.MaxTokens)
+	case "refusal":
+		log.Printf("Anthropic API: model refused to respond")
+	}
+
+	content := strings.TrimSpace(text.String())
+	usage := Usage{PromptTokens: out.Usage.InputTokens, CompletionTokens: out.Usage.OutputTokens}
+	thinking := strings.TrimSpace(reasoning.String())
+	if len(tcs) > 0 {
+		return LLMResponse{Content: content, HasToolCalls: true, ToolCalls: tcs, Usage: usage, Reasoning: thinking}, nil
+	}
+	return LLMResponse{Content: content, HasToolCalls: false, Usage: usage, Reasoning: thinking}, nil
+}
+
+// toAnthropicMessages converts provider messages into the Messages API layout:
+// system messages are joined into the top-level system prompt, assistant tool
+// calls become tool_use blocks, tool results become tool_result blocks on a
+// user turn, and image parts become image blocks. Consecutive messages with
+// the same role are merged because the API requires user and assistant turns
+// to alternate.
+func toAnthropicMessages(messages []Message) (string, []anthropicMessage) {
+	var system []string
+	var out []anthropicMessage
+
+	appendBlocks := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+		case "assistant":
+			var blocks []anthropicBlock
+			if m.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage("{}")
+				if len(tc.Arguments) > 0 {
+					input, _ = json.Marshal(tc.Arguments)
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Name, Input: input})
+			}
+			appendBlocks("assistant", blocks...)
+		case "tool":
+			appendBlocks("user", anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+		default:
+			if len(m.Parts) > 0 {
+				var blocks []anthropicBlock
+				for _, part := range m.Parts {
+					if part.Type == "image_url" {
+						if blk, ok := anthropicImageBlock(part.ImageURL); ok {
+							blocks = append(blocks, blk)
+						}
+					} else if part.Text != "" {
+						blocks = append(blocks, anthropicBlock{Type: "text", Text: part.Text})
+					}
+				}
+				appendBlocks("user", blocks...)
+			} else if m.Content != "" {
+				appendBlocks("user", anthropicBlock{Type: "text", Text: m.Content})
+			}
+		}
+	}
+	return strings.Join(system, "\n\n"), out
+}
@@ -0,0 +1,119 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnthropicToolUseParsing(t *testing.T) {
+	var got anthropicRequest
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth/version headers: %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{
+		  "content": [
+		    {"type": "text", "text": "Let me send that."},
+		    {"type": "tool_use", "id": "toolu_01", "name": "message", "input": {"content": "Hello from tool"}}
+		  ],
+		  "stop_reason": "tool_use"
+		}`))
+	}))
+	defer h.Close()
+
+	p := NewAnthropicProvider("test-key", h.URL, 60)
+	p.Client = &http.Client{Timeout: 5 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	msgs := []Message{
+		{Role: "system", Content: "You are Picobot."},
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "trigger"},
+	}
+	tools := []ToolDefinition{{Name: "message", Description: "send"}}
+	resp, err := p.Chat(ctx, msgs, tools, "claude-test", ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.System != "You are Picobot.\n\nBe brief." {
+		t.Fatalf("expected system prompts joined at top level, got %q", got.System)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Fatalf("expected a single user message, got %+v", got.Messages)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].InputSchema == nil {
+		t.Fatalf("expected tool with input_schema, got %+v", got.Tools)
+	}
+	if !resp.HasToolCalls || len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected one tool call, got: has=%v len=%d", resp.HasToolCalls, len(resp.ToolCalls))
+	}
+	if resp.ToolCalls[0].ID != "toolu_01" || resp.ToolCalls[0].Arguments["content"] != "Hello from tool" {
+		t.Fatalf("unexpected tool call: %+v", resp.ToolCalls[0])
+	}
+	if resp.Content != "Let me send that." {
+		t.Fatalf("unexpected content: %q", resp.Content)
+	}
+}
+
+func TestToAnthropicMessagesToolResults(t *testing.T) {
+	msgs := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "read two files"},
+		{Role: "assistant", Content: "", ToolCalls: []ToolCall{
+			{ID: "a", Name: "filesystem", Arguments: map[string]interface{}{"path": "x"}},
+			{ID: "b", Name: "filesystem"},
+		}},
+		{Role: "tool", ToolCallID: "a", Content: "X"},
+		{Role: "tool", ToolCallID: "b", Content: "Y"},
+	}
+	system, out := toAnthropicMessages(msgs)
+	if system != "sys" {
+		t.Fatalf("unexpected system: %q", system)
+	}
+	if len(out) != 3 {
+		t.Fatalf("expected user/assistant/user turns, got %d: %+v", len(out), out)
+	}
+	asst := out[1]
+	if asst.Role != "assistant" || len(asst.Content) != 2 || asst.Content[0].Type != "tool_use" {
+		t.Fatalf("unexpected assistant turn: %+v", asst)
+	}
+	if string(asst.Content[1].Input) != "{}" {
+		t.Fatalf("expected empty input object for argument-less tool call, got %s", asst.Content[1].Input)
+	}
+	results := out[2]
+	if results.Role != "user" || len(results.Content) != 2 {
+		t.Fatalf("expected both tool results merged into one user turn, got %+v", results)
+	}
+	if results.Content[0].Type != "tool_result" || results.Content[0].ToolUseID != "a" || results.Content[1].ToolUseID != "b" {
+		t.Fatalf("unexpected tool results: %+v", results.Content)
+	}
+}
+
+func TestToAnthropicMessagesImageParts(t *testing.T) {
+	_, msgs := toAnthropicMessages([]Message{{
+		Role: "user",
+		Parts: []ContentPart{
+			{Type: "text", Text: "what is this?"},
+			{Type: "image_url", ImageURL: "data:image/jpeg;base64,QUJD"},
+		},
+	}})
+	if len(msgs) != 1 || len(msgs[0].Content) != 2 {
+		t.Fatalf("expected one user turn with two blocks, got %+v", msgs)
+	}
+	img := msgs[0].Content[1]
+	if img.Type != "image" || img.Source == nil || img.Source.Type != "base64" || img.Source.MediaType != "image/jpeg" || img.Source.Data != "QUJD" {
+		t.Fatalf("unexpected image block: %+v", img)
+	}
+}
@@ -0,0 +1,175 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into vectors for semantic similarity. The returned slice
+// has one vector per input text, in order; all vectors share one dimension.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+const defaultEmbeddingModel = "text-embedding-3-small"
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed calls the OpenAI-compatible /embeddings endpoint with EmbeddingModel.
+// It uses the same retry policy as Chat.
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if p.APIKey == "" {
+		return nil, errors.New("OpenAI provider: API key is not configured")
+	}
+	model := p.EmbeddingModel
+	if model == "" {
+		model = defaultEmbeddingModel
+	}
+
+	resp, err := p.post(ctx, "/embeddings", embeddingRequest{Model: model, Input: texts}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Data) != len(texts) {
+		return nil, fmt.Errorf("OpenAI embeddings: got %d vectors for %d inputs", len(out.Data), len(texts))
+	}
+	// the API documents data as ordered by index, but do not rely on it
+	sort.Slice(out.Data, func(i, j int) bool { return out.Data[i].Index < out.Data[j].Index })
+	vecs := make([][]float32, len(out.Data))
+	for i, d := range out.Data {
+		vecs[i] = d.Embedding
+	}
+	return vecs, nil
+}
+
+// HashEmbedder is a local embedder for offline use. It hashes lowercase words
+// and adjacent word pairs into a fixed number of dimensions (the "hashing
+// trick") and L2-normalizes the result, so cosine similarity approximates
+// weighted keyword overlap. It needs no network or model, but captures no
+// meaning beyond shared words.
+type HashEmbedder struct {
+	Dims int
+}
+
+// NewHashEmbedder returns a HashEmbedder; dims <= 0 defaults to 256.
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = 256
+	}
+	return &HashEmbedder{Dims: dims}
+}
+
+// Embed implements Embedder. It never fails.
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, t := range texts {
+		vecs[i] = e.embed(t)
+	}
+	return vecs, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	v := make([]float32, e.Dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	add := func(feature string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(feature))
+		sum := h.Sum32()
+		// the top bit picks the sign so colliding features tend to cancel out
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		v[int(sum%uint32(e.Dims))] += weight
+	}
+	for i, w := range words {
+		add(w, 1)
+		if i > 0 {
+			add(words[i-1]+" "+w, 0.5)
+		}
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		inv := float32(1 / math.Sqrt(norm))
+		for i := range v {
+			v[i] *= inv
+		}
+	}
+	return v
+}
+
+// FallbackEmbedder uses Primary, typically a remote embeddings endpoint, and
+// Fallback when Primary fails (offline, rate limited, or misconfigured).
+// Vectors from the two usually differ in dimension; CosineSimilarity scores
+// such pairs 0 rather than comparing them.
+type FallbackEmbedder struct {
+	Primary  Embedder
+	Fallback Embedder
+}
+
+// NewFallbackEmbedder returns a FallbackEmbedder.
+func NewFallbackEmbedder(primary, fallback Embedder) *FallbackEmbedder {
+	return &FallbackEmbedder{Primary: primary, Fallback: fallback}
+}
+
+// Embed implements Embedder.
+func (e *FallbackEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs, err := e.Primary.Embed(ctx, texts)
+	if err == nil {
+		return vecs, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+	log.Printf("embeddings failed: %v; using the local fallback embedder", err)
+	return e.Fallback.Embed(ctx, texts)
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// either is a zero vector or their lengths differ.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
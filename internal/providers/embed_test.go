@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/local/picobot/internal/config"
+)
+
+func TestOpenAIEmbed(t *testing.T) {
+	var got embeddingRequest
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		// out of order on purpose: vectors must be matched to inputs by index
+		w.Write([]byte(`{"data": [
+		  {"index": 1, "embedding": [0, 1]},
+		  {"index": 0, "embedding": [1, 0]}
+		]}`))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.Client = &http.Client{Timeout: 5 * time.Second}
+	p.EmbeddingModel = "embed-x"
+
+	vecs, err := p.Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got.Model != "embed-x" || len(got.Input) != 2 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if len(vecs) != 2 || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Fatalf("unexpected vectors: %v", vecs)
+	}
+}
+
+func TestHashEmbedderSimilarity(t *testing.T) {
+	e := NewHashEmbedder(0)
+	vecs, _ := e.Embed(context.Background(), []string{
+		"Buy milk on the way home",
+		"remember to buy MILK",
+		"quarterly tax filing deadline",
+	})
+	if len(vecs[0]) != 256 {
+		t.Fatalf("expected 256 dims, got %d", len(vecs[0]))
+	}
+	related := CosineSimilarity(vecs[0], vecs[1])
+	unrelated := CosineSimilarity(vecs[0], vecs[2])
+	if related <= unrelated {
+		t.Fatalf("expected shared words to score higher: related=%.3f unrelated=%.3f", related, unrelated)
+	}
+	if s := CosineSimilarity(vecs[0], vecs[0]); s < 0.999 {
+		t.Fatalf("expected self-similarity of 1, got %.3f", s)
+	}
+}
+
+func TestNewEmbedderFromConfig(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Providers.OpenAI = &config.ProviderConfig{APIKey: "k"}
+	if _, ok := NewEmbedderFromConfig(cfg).(*HashEmbedder); !ok {
+		t.Fatalf("expected local embedder without an embedding model")
+	}
+	cfg.Providers.OpenAI.EmbeddingModel = "text-embedding-3-small"
+	fe, ok := NewEmbedderFromConfig(cfg).(*FallbackEmbedder)
+	if !ok {
+		t.Fatalf("expected a fallback embedder, got %T", NewEmbedderFromConfig(cfg))
+	}
+	if p, ok := fe.Primary.(*OpenAIProvider); !ok || p.EmbeddingModel != "text-embedding-3-small" {
+		t.Fatalf("expected OpenAI as the primary embedder, got %T", fe.Primary)
+	}
+	if _, ok := fe.Fallback.(*HashEmbedder); !ok {
+		t.Fatalf("expected the local embedder as the fallback, got %T", fe.Fallback)
+	}
+}
+
+func TestFallbackEmbedderUsesLocalOnError(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer h.Close()
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.MaxRetries = 0
+
+	vecs, err := NewFallbackEmbedder(p, NewHashEmbedder(8)).Embed(context.Background(), []string{"offline"})
+	if err != nil {
+		t.Fatalf("expected the fallback to answer, got %v", err)
+	}
+	if len(vecs) != 1 || len(vecs[0]) != 8 {
+		t.Fatalf("expected one local vector, got %v", vecs)
+	}
+}
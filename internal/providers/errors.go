@@ -0,0 +1,84 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Typed provider errors. An *APIError unwraps to one of these when its status
+// and body match, so callers can use errors.Is without inspecting status codes.
+var (
+	// ErrRateLimited means the provider rejected the request with 429.
+	ErrRateLimited = errors.New("rate limited by provider")
+	// ErrAuth means the API key is missing, invalid, or lacks permission (401/403).
+	ErrAuth = errors.New("provider authentication failed")
+	// ErrContextLength means the prompt exceeded the model's context window.
+	ErrContextLength = errors.New("context length exceeded")
+)
+
+// APIError is returned when a provider's HTTP API answers with a non-2xx status.
+type APIError struct {
+	Provider   string // human-readable provider name, e.g. "OpenAI"
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, zero if absent
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s API error: %s", e.Provider, e.Status)
+	}
+	return fmt.Sprintf("%s API error: %s - %s", e.Provider, e.Status, e.Body)
+}
+
+// Unwrap maps the status code (and for 400s, the body) to a typed sentinel error.
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == 429:
+		return ErrRateLimited
+	case e.StatusCode == 401 || e.StatusCode == 403:
+		return ErrAuth
+	case e.StatusCode == 400 || e.StatusCode == 413:
+		if isContextLengthBody(e.Body) {
+			return ErrContextLength
+		}
+	}
+	return nil
+}
+
+// isContextLengthBody recognises the context-overflow messages used by OpenAI,
+// OpenRouter, and Anthropic.
+func isContextLengthBody(body string) bool {
+	b := strings.ToLower(body)
+	return strings.Contains(b, "context_length") ||
+		strings.Contains(b, "context length") ||
+		strings.Contains(b, "context window") ||
+		strings.Contains(b, "prompt is too long")
+}
+
+// IsTransient reports whether err is worth retrying against another endpoint:
+// rate limiting (429), server errors (5xx), timeouts, and connection failures.
+// Client errors such as bad requests or invalid credentials are not transient.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == 429 || apiErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
@@ -1,18 +1,155 @@
 package providers
 
-import "github.com/local/picobot/internal/config"
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/local/picobot/internal/config"
+)
 
 // NewProviderFromConfig creates a provider based on the configuration.
 // Simple rules (v0):
-//   - if OpenAI API key present -> OpenAI
+//   - if a failover chain is configured -> FailoverProvider over its entries
+//   - else if Anthropic API key present -> Anthropic (native Messages API)
+//   - else if an ollama entry is present -> Ollama (native /api/chat)
+//   - else if OpenAI API key present -> OpenAI
 //   - else fallback to stub
+//
+// Anthropic and Ollama are checked before OpenAI because the default config
+// always carries an OpenAI placeholder key, so an explicit entry for either is
+// the stronger signal.
 func NewProviderFromConfig(cfg config.Config) LLMProvider {
+	if fc := cfg.Providers.Failover; fc != nil && len(fc.Chain) > 0 {
+		if p := newFailoverFromConfig(cfg, fc); p != nil {
+			return p
+		}
+	}
+	if cfg.Providers.Anthropic != nil && cfg.Providers.Anthropic.APIKey != "" {
+		return NewAnthropicProvider(
+			cfg.Providers.Anthropic.APIKey,
+			cfg.Providers.Anthropic.APIBase,
+			cfg.Agents.Defaults.RequestTimeoutS,
+		)
+	}
+	if cfg.Providers.Ollama != nil {
+		return newOllamaFromConfig(cfg.Providers.Ollama, "", cfg.Agents.Defaults.RequestTimeoutS)
+	}
 	if cfg.Providers.OpenAI != nil && cfg.Providers.OpenAI.APIKey != "" {
-		return NewOpenAIProvider(
+		p := NewOpenAIProvider(
 			cfg.Providers.OpenAI.APIKey,
 			cfg.Providers.OpenAI.APIBase,
 			cfg.Agents.Defaults.RequestTimeoutS,
 		)
+		applyMaxRetries(p, cfg.Providers.OpenAI)
+		return p
 	}
 	return NewStubProvider()
 }
+
+// NewEmbedderFromConfig returns the OpenAI-compatible embeddings endpoint,
+// falling back to the local HashEmbedder when a request fails, if
+// providers.openai has an API key and an embeddingModel; otherwise it returns
+// the HashEmbedder alone. The model must be set explicitly because not every
+// OpenAI-compatible service (e.g. OpenRouter) offers embeddings.
+func NewEmbedderFromConfig(cfg config.Config) Embedder {
+	if oc := cfg.Providers.OpenAI; oc != nil && oc.APIKey != "" && oc.EmbeddingModel != "" {
+		p := NewOpenAIProvider(oc.APIKey, oc.APIBase, cfg.Agents.Defaults.RequestTimeoutS)
+		applyMaxRetries(p, oc)
+		p.EmbeddingModel = oc.EmbeddingModel
+		return NewFallbackEmbedder(p, NewHashEmbedder(0))
+	}
+	return NewHashEmbedder(0)
+}
+
+// NewProviderFromFlag creates a provider from a --provider flag value, bypassing
+// the config. Supported values are "stub" and "script:<path to scenario.json>".
+func NewProviderFromFlag(spec string) (LLMProvider, error) {
+	switch {
+	case spec == "stub":
+		return NewStubProvider(), nil
+	case strings.HasPrefix(spec, "script:"):
+		path := strings.TrimPrefix(spec, "script:")
+		if path == "" {
+			return nil, fmt.Errorf("--provider script: needs a scenario path, e.g. script:demo.json")
+		}
+		return NewScriptProvider(path)
+	default:
+		return nil, fmt.Errorf("unknown --provider %q (use stub or script:<path>)", spec)
+	}
+}
+
+// newFailoverFromConfig builds a FailoverProvider, skipping chain entries that
+// name an unknown provider or have no API key. Returns nil if none are usable.
+func newFailoverFromConfig(cfg config.Config, fc *config.FailoverConfig) LLMProvider {
+	timeout := cfg.Agents.Defaults.RequestTimeoutS
+	var entries []FailoverEntry
+	for _, t := range fc.Chain {
+		var p LLMProvider
+		switch t.Provider {
+		case "ollama":
+			oc := cfg.Providers.Ollama
+			if oc == nil {
+				oc = &config.OllamaConfig{}
+			}
+			p = newOllamaFromConfig(oc, t.APIBase, timeout)
+		case "openai", "anthropic":
+			base := cfg.Providers.OpenAI
+			if t.Provider == "anthropic" {
+				base = cfg.Providers.Anthropic
+			}
+			key, apiBase := t.APIKey, t.APIBase
+			if base != nil {
+				if key == "" {
+					key = base.APIKey
+				}
+				if apiBase == "" {
+					apiBase = base.APIBase
+				}
+			}
+			if key == "" {
+				log.Printf("failover: no API key for %q in chain, skipping", t.Provider)
+				continue
+			}
+			if t.Provider == "anthropic" {
+				p = NewAnthropicProvider(key, apiBase, timeout)
+			} else {
+				op := NewOpenAIProvider(key, apiBase, timeout)
+				applyMaxRetries(op, base)
+				p = op
+			}
+		default:
+			log.Printf("failover: unknown provider %q in chain, skipping", t.Provider)
+			continue
+		}
+		entries = append(entries, FailoverEntry{Name: t.Provider, Provider: p, Model: t.Model})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return NewFailoverProvider(entries, fc.FailureThreshold, time.Duration(fc.CooldownS)*time.Second)
+}
+
+// newOllamaFromConfig builds an Ollama provider; apiBase overrides oc.APIBase when set.
+func newOllamaFromConfig(oc *config.OllamaConfig, apiBase string, timeoutSecs int) *OllamaProvider {
+	if apiBase == "" {
+		apiBase = oc.APIBase
+	}
+	p := NewOllamaProvider(apiBase, timeoutSecs)
+	p.KeepAlive = oc.KeepAlive
+	p.NumCtx = oc.NumCtx
+	return p
+}
+
+// applyMaxRetries copies the configured retry count onto an OpenAI provider.
+func applyMaxRetries(p *OpenAIProvider, pc *config.ProviderConfig) {
+	if pc == nil || pc.MaxRetries == 0 {
+		return
+	}
+	if pc.MaxRetries < 0 {
+		p.MaxRetries = 0
+		return
+	}
+	p.MaxRetries = pc.MaxRetries
+}
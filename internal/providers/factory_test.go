@@ -16,6 +16,17 @@ func TestNewProviderFromConfig_PicksOpenAI(t *testing.T) {
 	}
 }
 
+func TestNewProviderFromConfig_PicksAnthropic(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Providers.OpenAI = &config.ProviderConfig{APIKey: "sk-or-v1-REPLACE_ME"}
+	cfg.Providers.Anthropic = &config.ProviderConfig{APIKey: "test"}
+	p := NewProviderFromConfig(cfg)
+	_, ok := p.(*AnthropicProvider)
+	if !ok {
+		t.Fatalf("expected AnthropicProvider, got %T", p)
+	}
+}
+
 func TestNewProviderFromConfig_FallbacksToStub(t *testing.T) {
 	cfg := config.Config{}
 	p := NewProviderFromConfig(cfg)
@@ -24,3 +35,64 @@ func TestNewProviderFromConfig_FallbacksToStub(t *testing.T) {
 		t.Fatalf("expected StubProvider, got %T", p)
 	}
 }
+
+func TestNewProviderFromConfig_BuildsFailoverChain(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Providers.Anthropic = &config.ProviderConfig{APIKey: "test"}
+	cfg.Providers.Failover = &config.FailoverConfig{Chain: []config.FailoverTarget{
+		{Provider: "anthropic"},
+		{Provider: "openai", APIKey: "other", Model: "gpt-4o-mini"},
+		{Provider: "bogus"},
+	}}
+	p := NewProviderFromConfig(cfg)
+	fp, ok := p.(*FailoverProvider)
+	if !ok {
+		t.Fatalf("expected FailoverProvider, got %T", p)
+	}
+	if len(fp.entries) != 2 {
+		t.Fatalf("expected 2 usable entries, got %d", len(fp.entries))
+	}
+	if _, ok := fp.entries[0].Provider.(*AnthropicProvider); !ok {
+		t.Fatalf("expected first entry to be Anthropic, got %T", fp.entries[0].Provider)
+	}
+}
+
+func TestNewProviderFromConfig_PicksOllama(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Providers.OpenAI = &config.ProviderConfig{APIKey: "sk-or-v1-REPLACE_ME"}
+	cfg.Providers.Ollama = &config.OllamaConfig{APIBase: "http://pi.local:11434", NumCtx: 8192}
+	p := NewProviderFromConfig(cfg)
+	op, ok := p.(*OllamaProvider)
+	if !ok {
+		t.Fatalf("expected OllamaProvider, got %T", p)
+	}
+	if op.APIBase != "http://pi.local:11434" || op.NumCtx != 8192 {
+		t.Fatalf("unexpected provider settings: %+v", op)
+	}
+}
+
+func TestContextWindowLookup(t *testing.T) {
+	cases := map[string]int{
+		"claude-sonnet-4-5":       200000,
+		"google/gemini-2.5-flash": 1048576,
+		"llama3.2:3b":             131072,
+		"llama3:8b":               8192,
+		"some-unknown-model":      defaultContextWindow,
+	}
+	for model, want := range cases {
+		if got := ContextWindow(model); got != want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
+
+func TestDefaultChatOptionsLeavesTemperatureUnset(t *testing.T) {
+	opts := DefaultChatOptions(config.AgentDefaults{MaxTokens: 512})
+	if opts.Temperature != nil {
+		t.Fatalf("expected no temperature unless configured, got %v", *opts.Temperature)
+	}
+	temp := 0.3
+	if opts = ApplyProfile(opts, config.ModelProfile{Temperature: &temp}); opts.Temperature == nil || *opts.Temperature != 0.3 {
+		t.Fatalf("expected the profile's temperature, got %v", opts.Temperature)
+	}
+}
@@ -0,0 +1,188 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrAllProvidersUnavailable is returned by FailoverProvider when every entry in
+// the chain is skipped because its circuit breaker is open.
+var ErrAllProvidersUnavailable = errors.New("all providers unavailable (circuit open)")
+
+// FailoverEntry is one link in a failover chain.
+type FailoverEntry struct {
+	Name     string // used in logs, e.g. "openai" or "anthropic"
+	Provider LLMProvider
+	// Model overrides the requested model for this entry. When empty, the first
+	// entry uses the caller's model and later entries use their provider default,
+	// since model names are rarely portable between providers.
+	Model string
+}
+
+// FailoverProvider wraps an ordered chain of providers. Each call goes to the
+// first healthy entry and falls through to the next one on transient errors
+// (see IsTransient). After threshold consecutive transient failures an entry's
+// circuit opens and it is skipped for the cooldown period. After the cooldown
+// the circuit is half-open: a single call goes through as a trial and either
+// closes the circuit or re-opens it, while concurrent calls keep skipping it.
+type FailoverProvider struct {
+	entries   []*failoverState
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+type failoverState struct {
+	FailoverEntry
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time // zero while the circuit is closed
+	probing   bool      // a half-open trial call is in flight
+}
+
+// NewFailoverProvider builds a failover chain. threshold <= 0 defaults to 3
+// consecutive failures and cooldown <= 0 defaults to 60 seconds.
+func NewFailoverProvider(entries []FailoverEntry, threshold int, cooldown time.Duration) *FailoverProvider {
+	if threshold <= 0 {
+		threshold = 3
+	}
+	if cooldown <= 0 {
+		cooldown = 60 * time.Second
+	}
+	fp := &FailoverProvider{threshold: threshold, cooldown: cooldown, now: time.Now}
+	for _, e := range entries {
+		fp.entries = append(fp.entries, &failoverState{FailoverEntry: e})
+	}
+	return fp
+}
+
+// GetDefaultModel returns the first entry's model (or its provider default).
+func (p *FailoverProvider) GetDefaultModel() string {
+	if len(p.entries) == 0 {
+		return ""
+	}
+	if p.entries[0].Model != "" {
+		return p.entries[0].Model
+	}
+	return p.entries[0].Provider.GetDefaultModel()
+}
+
+// Chat implements LLMProvider.
+func (p *FailoverProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	return p.try(ctx, model, func(e *failoverState, m string) (LLMResponse, error) {
+		return e.Provider.Chat(ctx, messages, tools, m, opts)
+	})
+}
+
+// ChatStream implements StreamingProvider. Entries that cannot stream are called
+// through Chat, in which case onDelta is not invoked for that attempt. When an
+// entry fails after streaming some text, onDelta receives StreamReset before
+// the next entry is tried.
+func (p *FailoverProvider) ChatStream(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions, onDelta func(delta string)) (LLMResponse, error) {
+	streamed := false
+	forward := func(delta string) {
+		streamed = true
+		onDelta(delta)
+	}
+	return p.try(ctx, model, func(e *failoverState, m string) (LLMResponse, error) {
+		if streamed {
+			onDelta(StreamReset)
+			streamed = false
+		}
+		if sp, ok := e.Provider.(StreamingProvider); ok {
+			return sp.ChatStream(ctx, messages, tools, m, opts, forward)
+		}
+		return e.Provider.Chat(ctx, messages, tools, m, opts)
+	})
+}
+
+// try walks the chain, calling fn for each entry whose circuit allows it.
+func (p *FailoverProvider) try(ctx context.Context, model string, fn func(e *failoverState, model string) (LLMResponse, error)) (LLMResponse, error) {
+	var lastErr error
+	for i, e := range p.entries {
+		if !e.allow(p.now()) {
+			continue
+		}
+		m := e.Model
+		if m == "" {
+			if i == 0 {
+				m = model
+			} else {
+				m = e.Provider.GetDefaultModel()
+			}
+		}
+		resp, err := fn(e, m)
+		if err == nil {
+			e.succeed()
+			if resp.Model == "" {
+				resp.Model = m
+			}
+			return resp, nil
+		}
+		// Caller cancellation and non-transient errors (bad request, auth) would
+		// fail the same way everywhere, so return them without falling through.
+		if ctx.Err() != nil || !IsTransient(err) {
+			e.release()
+			return LLMResponse{}, err
+		}
+		if e.fail(p.now(), p.threshold, p.cooldown) {
+			log.Printf("failover: circuit open for %s (%s); skipping for %v", e.Name, m, p.cooldown)
+		}
+		log.Printf("failover: %s (%s) failed: %v", e.Name, m, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return LLMResponse{}, ErrAllProvidersUnavailable
+	}
+	return LLMResponse{}, fmt.Errorf("all providers failed: %w", lastErr)
+}
+
+// allow reports whether the entry may be called: its circuit is closed, or the
+// cooldown has elapsed and no other trial is in flight, in which case this
+// call becomes the half-open trial.
+func (e *failoverState) allow(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(e.openUntil) || e.probing {
+		return false
+	}
+	e.probing = true
+	return true
+}
+
+func (e *failoverState) succeed() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.openUntil = time.Time{}
+	e.probing = false
+}
+
+// fail records a transient failure and returns true if this failure opened
+// the circuit. A failed trial re-opens it straight away.
+func (e *failoverState) fail(now time.Time, threshold int, cooldown time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.probing || e.failures >= threshold {
+		e.openUntil = now.Add(cooldown)
+		e.probing = false
+		return true
+	}
+	return false
+}
+
+// release ends a trial that was inconclusive (canceled, or a non-transient
+// error), so the next call can try again.
+func (e *failoverState) release() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.probing = false
+}
@@ -0,0 +1,165 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scriptedProvider returns the queued errors in order, then succeeds.
+type scriptedProvider struct {
+	name  string
+	errs  []error
+	calls int
+}
+
+func (s *scriptedProvider) GetDefaultModel() string { return s.name + "-default" }
+
+func (s *scriptedProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return LLMResponse{}, err
+		}
+	}
+	return LLMResponse{Content: s.name + ":" + model}, nil
+}
+
+func unavailable() error {
+	return &APIError{Provider: "X", StatusCode: 503, Status: "503 Service Unavailable"}
+}
+
+func TestFailoverFallsThroughOnTransientError(t *testing.T) {
+	primary := &scriptedProvider{name: "primary", errs: []error{unavailable()}}
+	backup := &scriptedProvider{name: "backup"}
+	fp := NewFailoverProvider([]FailoverEntry{
+		{Name: "primary", Provider: primary},
+		{Name: "backup", Provider: backup, Model: "backup-model"},
+	}, 3, time.Minute)
+
+	resp, err := fp.Chat(context.Background(), nil, nil, "m1", ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "backup:backup-model" || resp.Model != "backup-model" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestFailoverStopsOnClientError(t *testing.T) {
+	bad := &APIError{Provider: "X", StatusCode: 400, Status: "400 Bad Request"}
+	primary := &scriptedProvider{name: "primary", errs: []error{bad}}
+	backup := &scriptedProvider{name: "backup"}
+	fp := NewFailoverProvider([]FailoverEntry{
+		{Name: "primary", Provider: primary},
+		{Name: "backup", Provider: backup},
+	}, 3, time.Minute)
+
+	_, err := fp.Chat(context.Background(), nil, nil, "m1", ChatOptions{})
+	if !errors.Is(err, error(bad)) {
+		t.Fatalf("expected the 400 error, got %v", err)
+	}
+	if backup.calls != 0 {
+		t.Fatalf("backup should not be called on a client error")
+	}
+}
+
+func TestFailoverCircuitOpensAndRecovers(t *testing.T) {
+	primary := &scriptedProvider{name: "primary", errs: []error{unavailable(), unavailable()}}
+	backup := &scriptedProvider{name: "backup"}
+	fp := NewFailoverProvider([]FailoverEntry{
+		{Name: "primary", Provider: primary},
+		{Name: "backup", Provider: backup},
+	}, 2, time.Minute)
+	now := time.Unix(1000, 0)
+	fp.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := fp.Chat(context.Background(), nil, nil, "m1", ChatOptions{}); err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+	// two failures open the circuit, so the third call skips primary entirely
+	if primary.calls != 2 {
+		t.Fatalf("expected primary to be skipped while open, got %d calls", primary.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	resp, err := fp.Chat(context.Background(), nil, nil, "m1", ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "primary:m1" {
+		t.Fatalf("expected trial call to primary after cooldown, got %q", resp.Content)
+	}
+}
+
+func TestFailoverAllUnavailable(t *testing.T) {
+	only := &scriptedProvider{name: "only", errs: []error{unavailable()}}
+	fp := NewFailoverProvider([]FailoverEntry{{Name: "only", Provider: only}}, 1, time.Minute)
+
+	if _, err := fp.Chat(context.Background(), nil, nil, "", ChatOptions{}); err == nil {
+		t.Fatalf("expected error from failing provider")
+	}
+	if _, err := fp.Chat(context.Background(), nil, nil, "", ChatOptions{}); !errors.Is(err, ErrAllProvidersUnavailable) {
+		t.Fatalf("expected ErrAllProvidersUnavailable, got %v", err)
+	}
+}
+
+func TestFailoverHalfOpenAllowsOneTrial(t *testing.T) {
+	only := &scriptedProvider{name: "only"}
+	fp := NewFailoverProvider([]FailoverEntry{{Name: "only", Provider: only}}, 1, time.Minute)
+	e := fp.entries[0]
+	now := time.Unix(1000, 0)
+
+	e.fail(now, 1, time.Minute)
+	if e.allow(now) {
+		t.Fatal("expected the circuit to be open")
+	}
+	now = now.Add(2 * time.Minute)
+	if !e.allow(now) || e.allow(now) {
+		t.Fatal("expected exactly one trial call after the cooldown")
+	}
+	// a failed trial re-opens the circuit for another cooldown
+	if !e.fail(now, 1, time.Minute) || e.allow(now.Add(30*time.Second)) {
+		t.Fatal("expected a failed trial to re-open the circuit")
+	}
+	now = now.Add(2 * time.Minute)
+	if !e.allow(now) {
+		t.Fatal("expected a new trial after the second cooldown")
+	}
+	e.succeed()
+	if !e.allow(now) || !e.allow(now) {
+		t.Fatal("expected a successful trial to close the circuit")
+	}
+}
+
+// brokenStream streams some text and then fails with a transient error.
+type brokenStream struct{ scriptedProvider }
+
+func (b *brokenStream) ChatStream(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions, onDelta func(string)) (LLMResponse, error) {
+	onDelta("partial answ")
+	return LLMResponse{}, unavailable()
+}
+
+func TestFailoverStreamResetsAfterPartialFailure(t *testing.T) {
+	fp := NewFailoverProvider([]FailoverEntry{
+		{Name: "primary", Provider: &brokenStream{scriptedProvider{name: "primary"}}},
+		{Name: "backup", Provider: &scriptedProvider{name: "backup"}},
+	}, 3, time.Minute)
+
+	var deltas []string
+	resp, err := fp.ChatStream(context.Background(), nil, nil, "m1", ChatOptions{}, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Content != "backup:backup-default" {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+	if len(deltas) != 2 || deltas[0] != "partial answ" || deltas[1] != StreamReset {
+		t.Fatalf("expected the partial text to be reset before falling over, got %q", deltas)
+	}
+}
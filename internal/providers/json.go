@@ -0,0 +1,218 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidJSON is returned by ChatJSON when the model's reply is still not
+// valid JSON for the requested schema after the repair retry.
+var ErrInvalidJSON = errors.New("model returned invalid JSON")
+
+// ResponseFormat asks the model for a JSON reply. Providers with native support
+// constrain generation to it (OpenAI response_format, Ollama format); others
+// rely on the instruction ChatJSON adds to the prompt.
+type ResponseFormat struct {
+	// Name identifies the schema, e.g. "memory_ranking". Defaults to "response".
+	Name string `json:"name,omitempty"`
+	// Schema is a JSON Schema for the reply. Nil accepts any JSON object.
+	Schema map[string]interface{} `json:"schema,omitempty"`
+}
+
+// ChatJSON asks the model for a JSON reply matching format, validates it and
+// decodes it into out. If the reply is not valid JSON or does not match the
+// schema, the model is shown the error and asked once to correct it. The last
+// response is returned even on error, with usage summed over both attempts;
+// the error wraps ErrInvalidJSON when the reply could not be used.
+func ChatJSON(ctx context.Context, p LLMProvider, messages []Message, model string, opts ChatOptions, format ResponseFormat, out interface{}) (LLMResponse, error) {
+	opts.ResponseFormat = &format
+	msgs := append(append([]Message(nil), messages...), Message{Role: "system", Content: jsonInstruction(format)})
+
+	var usage Usage
+	for attempt := 0; ; attempt++ {
+		resp, err := p.Chat(ctx, msgs, nil, model, opts)
+		usage.Add(resp.Usage)
+		resp.Usage = usage
+		if err != nil {
+			return resp, err
+		}
+		verr := decodeJSONReply(resp.Content, format.Schema, out)
+		if verr == nil {
+			return resp, nil
+		}
+		if attempt > 0 {
+			return resp, fmt.Errorf("%w: %v", ErrInvalidJSON, verr)
+		}
+		msgs = append(msgs,
+			Message{Role: "assistant", Content: resp.Content},
+			Message{Role: "user", Content: fmt.Sprintf("That reply was not valid: %v. Reply again with only the corrected JSON, no other text.", verr)},
+		)
+	}
+}
+
+// jsonInstruction tells the model to reply with JSON only, including the schema.
+func jsonInstruction(format ResponseFormat) string {
+	if format.Schema == nil {
+		return "Reply with a single JSON object only: no prose and no code fences."
+	}
+	schema, _ := json.Marshal(format.Schema)
+	return "Reply with a single JSON value only, no prose and no code fences, matching this JSON Schema:\n" + string(schema)
+}
+
+// decodeJSONReply parses content (tolerating a surrounding code fence),
+// validates it against schema and decodes it into out.
+func decodeJSONReply(content string, schema map[string]interface{}, out interface{}) error {
+	body := strings.TrimSpace(content)
+	if rest, ok := strings.CutPrefix(body, "```"); ok {
+		rest = strings.TrimPrefix(rest, "json")
+		body = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "```"))
+	}
+	if body == "" {
+		return errors.New("empty reply")
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return fmt.Errorf("not JSON: %v", err)
+	}
+	if schema != nil {
+		if err := validateSchema(v, schema, "$"); err != nil {
+			return err
+		}
+	} else if _, ok := v.(map[string]interface{}); !ok {
+		return errors.New("$: expected a JSON object")
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal([]byte(body), out)
+}
+
+// validateSchema checks v against the subset of JSON Schema used for tool and
+// response schemas in this repo: type, properties, required, items and enum.
+func validateSchema(v interface{}, schema map[string]interface{}, path string) error {
+	if typ, ok := schema["type"].(string); ok {
+		if err := checkType(v, typ); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if enum, ok := schema["enum"]; ok && !inEnum(v, enum) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, v, enum)
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := val[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names) // report the first error deterministically
+		for _, name := range names {
+			sub, ok := props[name].(map[string]interface{})
+			fv, present := val[name]
+			if !ok || !present {
+				continue
+			}
+			if err := validateSchema(fv, sub, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkType(v interface{}, typ string) error {
+	ok := false
+	switch typ {
+	case "object":
+		_, ok = v.(map[string]interface{})
+	case "array":
+		_, ok = v.([]interface{})
+	case "string":
+		_, ok = v.(string)
+	case "number":
+		_, ok = v.(float64)
+	case "integer":
+		f, isNum := v.(float64)
+		ok = isNum && f == math.Trunc(f)
+	case "boolean":
+		_, ok = v.(bool)
+	case "null":
+		ok = v == nil
+	default:
+		return nil // unknown types are not checked
+	}
+	if !ok {
+		return fmt.Errorf("expected %s, got %s", typ, jsonTypeName(v))
+	}
+	return nil
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func inEnum(v interface{}, enum interface{}) bool {
+	vb, _ := json.Marshal(v)
+	b, _ := json.Marshal(enum)
+	var values []interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return true // malformed enum: do not reject
+	}
+	for _, e := range values {
+		eb, _ := json.Marshal(e)
+		if string(eb) == string(vb) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList accepts []string or []interface{} (as decoded from JSON).
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case []string:
+		return t
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, s := range t {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// replyProvider returns the configured replies in order and records the messages it saw.
+type replyProvider struct {
+	replies []string
+	seen    [][]Message
+	opts    ChatOptions
+}
+
+func (p *replyProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	p.seen = append(p.seen, messages)
+	p.opts = opts
+	reply := p.replies[len(p.seen)-1]
+	return LLMResponse{Content: reply, Usage: Usage{PromptTokens: 10, CompletionTokens: 2}}, nil
+}
+
+func (p *replyProvider) GetDefaultModel() string { return "m" }
+
+var reminderFormat = ResponseFormat{
+	Name: "reminder",
+	Schema: map[string]interface{}{
+		"type":     "object",
+		"required": []string{"text", "delay"},
+		"properties": map[string]interface{}{
+			"text":  map[string]interface{}{"type": "string"},
+			"delay": map[string]interface{}{"type": "string"},
+			"kind":  map[string]interface{}{"type": "string", "enum": []string{"once", "recurring"}},
+		},
+	},
+}
+
+func TestChatJSONRepairsInvalidReply(t *testing.T) {
+	p := &replyProvider{replies: []string{
+		`{"text": "water plants"}`,
+		`{"text": "water plants", "delay": "2h", "kind": "once"}`,
+	}}
+	var out struct {
+		Text  string `json:"text"`
+		Delay string `json:"delay"`
+	}
+	resp, err := ChatJSON(context.Background(), p, []Message{{Role: "user", Content: "remind me to water plants in 2h"}}, "m", ChatOptions{}, reminderFormat, &out)
+	if err != nil {
+		t.Fatalf("expected repaired reply, got %v", err)
+	}
+	if out.Text != "water plants" || out.Delay != "2h" {
+		t.Fatalf("unexpected decode: %+v", out)
+	}
+	if resp.Usage.PromptTokens != 20 {
+		t.Fatalf("expected usage summed over both attempts, got %+v", resp.Usage)
+	}
+	if p.opts.ResponseFormat == nil || p.opts.ResponseFormat.Name != "reminder" {
+		t.Fatalf("expected response format in options, got %+v", p.opts)
+	}
+	retry := p.seen[1]
+	if last := retry[len(retry)-1]; last.Role != "user" || !strings.Contains(last.Content, `missing required field "delay"`) {
+		t.Fatalf("expected the validation error to be shown to the model, got %+v", last)
+	}
+}
+
+func TestChatJSONGivesUpAfterOneRepair(t *testing.T) {
+	p := &replyProvider{replies: []string{"sure!", `{"text": "x", "delay": "1h", "kind": "daily"}`}}
+	var out map[string]interface{}
+	_, err := ChatJSON(context.Background(), p, nil, "m", ChatOptions{}, reminderFormat, &out)
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("expected ErrInvalidJSON, got %v", err)
+	}
+	if len(p.seen) != 2 {
+		t.Fatalf("expected exactly two attempts, got %d", len(p.seen))
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+		},
+	}
+	cases := map[string]string{
+		`{"ids": [1, 2]}`:   "",
+		`{"ids": [1, 2.5]}`: "$.ids[1]: expected integer",
+		`{"ids": "1,2"}`:    "$.ids: expected array, got string",
+		`[1, 2]`:            "$: expected object, got array",
+	}
+	for in, want := range cases {
+		var v interface{}
+		json.Unmarshal([]byte(in), &v)
+		err := validateSchema(v, schema, "$")
+		if want == "" && err != nil {
+			t.Fatalf("%s: unexpected error %v", in, err)
+		}
+		if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Fatalf("%s: expected error containing %q, got %v", in, want, err)
+		}
+	}
+}
+
+func TestOpenAIBuildRequestSendsResponseFormat(t *testing.T) {
+	p := NewOpenAIProvider("test-key", "", 60)
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	b, _ := json.Marshal(p.buildRequest(msgs, nil, "m", ChatOptions{ResponseFormat: &reminderFormat}))
+	if !strings.Contains(string(b), `"response_format":{"type":"json_schema","json_schema":{"name":"reminder"`) {
+		t.Fatalf("expected json_schema response format in %s", b)
+	}
+	b, _ = json.Marshal(p.buildRequest(msgs, nil, "m", ChatOptions{ResponseFormat: &ResponseFormat{}}))
+	if !strings.Contains(string(b), `"response_format":{"type":"json_object"}`) {
+		t.Fatalf("expected json_object response format in %s", b)
+	}
+}
@@ -0,0 +1,46 @@
+package providers
+
+import "strings"
+
+// defaultContextWindow is assumed for models not listed in contextWindows.
+const defaultContextWindow = 32768
+
+// contextWindows lists known context window sizes (in tokens) by model name
+// substring. More specific entries must come before their prefixes.
+var contextWindows = []struct {
+	match  string
+	tokens int
+}{
+	{"claude", 200000},
+	{"gpt-4.1", 1047576},
+	{"gpt-5", 400000},
+	{"gpt-4o", 128000},
+	{"gpt-4-turbo", 128000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4-mini", 200000},
+	{"gemini", 1048576},
+	{"llama3.1", 131072},
+	{"llama3.2", 131072},
+	{"llama3.3", 131072},
+	{"llama-3.1", 131072},
+	{"llama-3.3", 131072},
+	{"llama3", 8192},
+	{"deepseek", 65536},
+	{"qwen3", 40960},
+	{"qwen2.5", 32768},
+	{"mistral", 32768},
+	{"stub-model", 1 << 20},
+}
+
+// ContextWindow returns the context window size in tokens for a model, matched
+// case-insensitively by name, or defaultContextWindow if the model is unknown.
+func ContextWindow(model string) int {
+	m := strings.ToLower(model)
+	for _, cw := range contextWindows {
+		if strings.Contains(m, cw.match) {
+			return cw.tokens
+		}
+	}
+	return defaultContextWindow
+}
@@ -0,0 +1,268 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider calls Ollama's native /api/chat endpoint. Unlike Ollama's
+// OpenAI-compatible shim it passes tool calls through for every model that
+// supports them, and exposes keep_alive and num_ctx.
+type OllamaProvider struct {
+	APIBase string // e.g. http://localhost:11434
+	Client  *http.Client
+	// KeepAlive controls how long the model stays loaded after a request
+	// (e.g. "5m", "-1" to keep it loaded). Empty uses the server default.
+	KeepAlive string
+	// NumCtx sets the context window in tokens. Zero uses the model default.
+	NumCtx int
+}
+
+func NewOllamaProvider(apiBase string, timeoutSecs int) *OllamaProvider {
+	if apiBase == "" {
+		apiBase = "http://localhost:11434"
+	}
+	if timeoutSecs <= 1 {
+		timeoutSecs = 60 // default 60 seconds
+	}
+	return &OllamaProvider{
+		APIBase: strings.TrimSuffix(strings.TrimRight(apiBase, "/"), "/v1"),
+		Client: &http.Client{
+			Timeout: time.Duration(timeoutSecs) * time.Second,
+		},
+	}
+}
+
+func (p *OllamaProvider) GetDefaultModel() string { return "llama3.2" }
+
+// Request/response shapes for /api/chat.
+type ollamaRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Tools     []toolWrapper   `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+	Options   *ollamaOptions  `json:"options,omitempty"`
+	// Format is "json" or a JSON Schema object constraining the reply.
+	Format interface{} `json:"format,omitempty"`
+	// Think enables thinking output: true, or a level for models that take one.
+	Think interface{} `json:"think,omitempty"`
+}
+
+// ollamaOptions are the model parameters Ollama accepts under "options".
+type ollamaOptions struct {
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // raw base64, no data: prefix
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // set on tool results
+	Thinking  string           `json:"thinking,omitempty"`  // reasoning, on responses only
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+// Chat calls /api/chat without streaming and returns a normalized response.
+// A ReasoningEffort enables thinking; only gpt-oss models take the level itself.
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	if model == "" {
+		model = p.GetDefaultModel()
+	}
+
+	reqBody := ollamaRequest{Model: model, Messages: toOllamaMessages(messages), KeepAlive: p.KeepAlive}
+	o := ollamaOptions{
+		NumCtx:      p.NumCtx,
+		NumPredict:  opts.MaxTokens,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
+	}
+	if o.NumCtx > 0 || o.NumPredict > 0 || o.Temperature != nil || o.TopP != nil || len(o.Stop) > 0 || o.Seed != nil {
+		reqBody.Options = &o
+	}
+	if opts.ReasoningEffort != "" {
+		if strings.Contains(model, "gpt-oss") {
+			reqBody.Think = opts.ReasoningEffort
+		} else {
+			reqBody.Think = true
+		}
+	}
+	if rf := opts.ResponseFormat; rf != nil {
+		if rf.Schema != nil {
+			reqBody.Format = rf.Schema
+		} else {
+			reqBody.Format = "json"
+		}
+	}
+	for _, t := range tools {
+		params := t.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		reqBody.Tools = append(reqBody.Tools, toolWrapper{
+			Type:     "function",
+			Function: functionDef{Name: t.Name, Description: t.Description, Parameters: params},
+		})
+	}
+
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.APIBase+"/api/chat", bytes.NewReader(b))
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		body := strings.TrimSpace(string(bodyBytes))
+		log.Printf("Ollama API non-2xx: %s body=%q", resp.Status, body)
+		return LLMResponse{}, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+
+	var out ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return LLMResponse{}, err
+	}
+
+	content, inline := splitThinking(out.Message.Content)
+	res := LLMResponse{
+		Content:   strings.TrimSpace(content),
+		Usage:     Usage{PromptTokens: out.PromptEvalCount, CompletionTokens: out.EvalCount},
+		Reasoning: joinReasoning(out.Message.Thinking, inline),
+	}
+	// Ollama does not assign tool call IDs; generate ones that are unique
+	// across responses, since session history keeps them and other providers
+	// (after a failover) require them to be distinct.
+	for _, tc := range out.Message.ToolCalls {
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		res.ToolCalls = append(res.ToolCalls, ToolCall{ID: fmt.Sprintf("call_%016x", rand.Uint64()), Name: tc.Function.Name, Arguments: args})
+	}
+	res.HasToolCalls = len(res.ToolCalls) > 0
+	return res, nil
+}
+
+// toOllamaMessages converts provider messages to /api/chat messages. Tool results
+// carry the tool's name rather than a call ID, and images must be raw base64.
+func toOllamaMessages(messages []Message) []ollamaMessage {
+	toolNames := map[string]string{} // tool call ID -> tool name
+	out := make([]ollamaMessage, 0, len(messages))
+	for _, m := range messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			toolNames[tc.ID] = tc.Name
+			var call ollamaToolCall
+			call.Function.Name = tc.Name
+			call.Function.Arguments = tc.Arguments
+			om.ToolCalls = append(om.ToolCalls, call)
+		}
+		if m.Role == "tool" {
+			om.ToolName = toolNames[m.ToolCallID]
+		}
+		for _, part := range m.Parts {
+			if part.Type != "image_url" {
+				continue
+			}
+			_, data, ok := strings.Cut(part.ImageURL, ";base64,")
+			if !ok || !strings.HasPrefix(part.ImageURL, "data:") {
+				log.Printf("Ollama: skipping image that is not a base64 data URL")
+				continue
+			}
+			om.Images = append(om.Images, data)
+		}
+		out = append(out, om)
+	}
+	return out
+}
+
+// OllamaModel describes a locally installed model reported by /api/tags.
+type OllamaModel struct {
+	Name          string
+	Size          int64  // bytes on disk
+	ParameterSize string // e.g. "8.0B"
+	Quantization  string // e.g. "Q4_K_M"
+}
+
+// ListModels returns the models installed on the Ollama server.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]OllamaModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.APIBase+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{Provider: "Ollama", StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(body))}
+	}
+
+	var tags struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Size    int64  `json:"size"`
+			Details struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, err
+	}
+	if tags.Models == nil {
+		return nil, errors.New("Ollama API returned no model list")
+	}
+	models := make([]OllamaModel, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, OllamaModel{
+			Name:          m.Name,
+			Size:          m.Size,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+		})
+	}
+	return models, nil
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaChatToolCalls(t *testing.T) {
+	var got ollamaRequest
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{
+		  "message": {
+		    "role": "assistant",
+		    "content": "",
+		    "tool_calls": [{"function": {"name": "web", "arguments": {"url": "https://example.com"}}}]
+		  },
+		  "done": true,
+		  "prompt_eval_count": 40,
+		  "eval_count": 7
+		}`))
+	}))
+	defer h.Close()
+
+	p := NewOllamaProvider(h.URL+"/v1", 60)
+	p.KeepAlive = "10m"
+	p.NumCtx = 8192
+
+	msgs := []Message{
+		{Role: "user", Content: "read the file"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Name: "filesystem", Arguments: map[string]interface{}{"action": "read"}}}},
+		{Role: "tool", Content: "file body", ToolCallID: "call_0"},
+	}
+	tools := []ToolDefinition{{Name: "web", Description: "fetch a URL"}}
+	resp, err := p.Chat(context.Background(), msgs, tools, "qwen2.5:7b", ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Model != "qwen2.5:7b" || got.Stream || got.KeepAlive != "10m" || got.Options == nil || got.Options.NumCtx != 8192 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Function.Name != "web" {
+		t.Fatalf("expected tools to be sent, got %+v", got.Tools)
+	}
+	if got.Messages[2].ToolName != "filesystem" {
+		t.Fatalf("expected tool result to carry the tool name, got %+v", got.Messages[2])
+	}
+
+	if !resp.HasToolCalls || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "web" || resp.ToolCalls[0].ID == "" {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.ToolCalls[0].Arguments["url"] != "https://example.com" {
+		t.Fatalf("unexpected arguments: %v", resp.ToolCalls[0].Arguments)
+	}
+	if resp.Usage.PromptTokens != 40 || resp.Usage.CompletionTokens != 7 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+	// history keeps the IDs, so every response must get new ones
+	again, _ := p.Chat(context.Background(), msgs, tools, "qwen2.5:7b", ChatOptions{})
+	if again.ToolCalls[0].ID == resp.ToolCalls[0].ID {
+		t.Fatalf("expected a new tool call ID per response, got %q twice", resp.ToolCalls[0].ID)
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			w.WriteHeader(404)
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"llama3.2:latest","size":2019393189,"details":{"parameter_size":"3.2B","quantization_level":"Q4_K_M"}}]}`))
+	}))
+	defer h.Close()
+
+	models, err := NewOllamaProvider(h.URL, 60).ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(models) != 1 || models[0].Name != "llama3.2:latest" || models[0].ParameterSize != "3.2B" {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+}
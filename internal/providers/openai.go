@@ -1,14 +1,16 @@
 package providers
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,6 +19,17 @@ type OpenAIProvider struct {
 	APIKey  string
 	APIBase string // e.g. https://api.openai.com/v1 or https://openrouter.ai/api/v1
 	Client  *http.Client
+	// MaxRetries is how many times a retryable failure (429, 5xx, network) is
+	// retried before giving up. Zero disables retries.
+	MaxRetries int
+	// RetryBaseDelay is the first backoff step; it doubles on every attempt.
+	RetryBaseDelay time.Duration
+	// EmbeddingModel is used by Embed. Empty uses text-embedding-3-small.
+	EmbeddingModel string
+
+	// noJSONSchema is set once the API has rejected a json_schema response
+	// format; JSON replies are then requested with json_object.
+	noJSONSchema atomic.Bool
 }
 
 func NewOpenAIProvider(apiKey, apiBase string, timeoutSecs int) *OpenAIProvider {
@@ -32,6 +45,8 @@ func NewOpenAIProvider(apiKey, apiBase string, timeoutSecs int) *OpenAIProvider
 		Client: &http.Client{
 			Timeout: time.Duration(timeoutSecs) * time.Second,
 		},
+		MaxRetries:     defaultMaxRetries,
+		RetryBaseDelay: defaultRetryBaseDelay,
 	}
 }
 
@@ -42,6 +57,41 @@ type chatRequest struct {
 	Model    string        `json:"model"`
 	Messages []messageJSON `json:"messages"`
 	Tools    []toolWrapper `json:"tools,omitempty"`
+	Stream   bool          `json:"stream,omitempty"`
+	// StreamOptions asks for a final usage chunk when streaming.
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+
+	// Generation parameters; unset fields are omitted so the server default applies.
+	MaxTokens           int      `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int      `json:"max_completion_tokens,omitempty"`
+	Temperature         *float64 `json:"temperature,omitempty"`
+	TopP                *float64 `json:"top_p,omitempty"`
+	Stop                []string `json:"stop,omitempty"`
+	Seed                *int     `json:"seed,omitempty"`
+	ReasoningEffort     string   `json:"reasoning_effort,omitempty"`
+
+	ResponseFormat *responseFormatJSON `json:"response_format,omitempty"`
+}
+
+// responseFormatJSON is {"type":"json_object"} or {"type":"json_schema","json_schema":{...}}.
+type responseFormatJSON struct {
+	Type       string          `json:"type"`
+	JSONSchema *jsonSchemaJSON `json:"json_schema,omitempty"`
+}
+
+type jsonSchemaJSON struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// usageJSON is the OpenAI "usage" block.
+type usageJSON struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
 }
 
 // toolWrapper is the OpenAI tools array element: {"type": "function", "function": {...}}
@@ -57,12 +107,23 @@ type functionDef struct {
 }
 
 type messageJSON struct {
-	Role       string         `json:"role"`
-	Content    string         `json:"content"`
+	Role string `json:"role"`
+	// Content is a string, or a []contentPartJSON for multimodal messages.
+	Content    interface{}    `json:"content"`
 	ToolCallID string         `json:"tool_call_id,omitempty"`
 	ToolCalls  []toolCallJSON `json:"tool_calls,omitempty"`
 }
 
+type contentPartJSON struct {
+	Type     string        `json:"type"` // "text" | "image_url"
+	Text     string        `json:"text,omitempty"`
+	ImageURL *imageURLJSON `json:"image_url,omitempty"`
+}
+
+type imageURLJSON struct {
+	URL string `json:"url"`
+}
+
 type toolCallJSON struct {
 	ID       string               `json:"id"`
 	Type     string               `json:"type"`
@@ -78,26 +139,57 @@ type messageResponseJSON struct {
 	Role      string         `json:"role"`
 	Content   string         `json:"content"`
 	ToolCalls []toolCallJSON `json:"tool_calls,omitempty"`
+	// Reasoning output: reasoning_content (DeepSeek, vLLM) or reasoning (OpenRouter).
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	Reasoning        string `json:"reasoning,omitempty"`
 }
 
 type chatResponse struct {
 	Choices []struct {
-		Message messageResponseJSON `json:"message"`
+		Message      messageResponseJSON `json:"message"`
+		FinishReason string              `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *usageJSON `json:"usage,omitempty"`
 }
 
-// Chat calls an OpenAI-compatible chat completion endpoint and returns a simplified response.
-func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (LLMResponse, error) {
-	if p.APIKey == "" {
-		return LLMResponse{}, errors.New("OpenAI provider: API key is not configured")
+// buildRequest converts provider messages, tools and options to the wire format.
+func (p *OpenAIProvider) buildRequest(messages []Message, tools []ToolDefinition, model string, opts ChatOptions) chatRequest {
+	reqBody := chatRequest{Model: model, Messages: make([]messageJSON, 0, len(messages))}
+	reqBody.Stop = opts.Stop
+	reqBody.Seed = opts.Seed
+	if opts.ReasoningEffort != "" {
+		// Reasoning models reject max_tokens and sampling parameters.
+		reqBody.ReasoningEffort = opts.ReasoningEffort
+		reqBody.MaxCompletionTokens = opts.MaxTokens
+	} else {
+		reqBody.MaxTokens = opts.MaxTokens
+		reqBody.Temperature = opts.Temperature
+		reqBody.TopP = opts.TopP
 	}
-	if model == "" {
-		model = p.GetDefaultModel()
+	if rf := opts.ResponseFormat; rf != nil {
+		if rf.Schema == nil || p.noJSONSchema.Load() {
+			reqBody.ResponseFormat = &responseFormatJSON{Type: "json_object"}
+		} else {
+			name := rf.Name
+			if name == "" {
+				name = "response"
+			}
+			reqBody.ResponseFormat = &responseFormatJSON{Type: "json_schema", JSONSchema: &jsonSchemaJSON{Name: name, Schema: rf.Schema}}
+		}
 	}
-
-	reqBody := chatRequest{Model: model, Messages: make([]messageJSON, 0, len(messages))}
 	for _, m := range messages {
 		mj := messageJSON{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		if len(m.Parts) > 0 {
+			parts := make([]contentPartJSON, 0, len(m.Parts))
+			for _, part := range m.Parts {
+				if part.Type == "image_url" {
+					parts = append(parts, contentPartJSON{Type: "image_url", ImageURL: &imageURLJSON{URL: part.ImageURL}})
+				} else {
+					parts = append(parts, contentPartJSON{Type: "text", Text: part.Text})
+				}
+			}
+			mj.Content = parts
+		}
 		// Convert provider ToolCall to JSON-serializable toolCallJSON
 		for _, tc := range m.ToolCalls {
 			argsBytes, _ := json.Marshal(tc.Arguments)
@@ -131,36 +223,104 @@ func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []T
 			})
 		}
 	}
+	return reqBody
+}
 
+// post sends reqBody to path (e.g. "/chat/completions") and returns the response
+// on 2xx. Retryable failures (see IsTransient) are retried up to MaxRetries times
+// with jittered exponential backoff, honouring any Retry-After header. The
+// returned error is an *APIError for non-2xx responses. The caller must close the body.
+func (p *OpenAIProvider) post(ctx context.Context, path string, reqBody interface{}, stream bool) (*http.Response, error) {
 	b, err := json.Marshal(reqBody)
 	if err != nil {
-		return LLMResponse{}, err
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/chat/completions", p.APIBase)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(b)))
+	for attempt := 0; ; attempt++ {
+		resp, err := p.postOnce(ctx, path, b, stream)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= p.MaxRetries || ctx.Err() != nil || !IsTransient(err) {
+			return nil, err
+		}
+		var retryAfter time.Duration
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			retryAfter = apiErr.RetryAfter
+		}
+		delay, ok := retryDelay(attempt, p.RetryBaseDelay, retryAfter)
+		if !ok {
+			log.Printf("OpenAI API: Retry-After %v exceeds %v, not retrying", retryAfter, maxRetryDelay)
+			return nil, err
+		}
+		log.Printf("OpenAI API: attempt %d/%d failed (%v), retrying in %v", attempt+1, p.MaxRetries+1, err, delay.Round(time.Millisecond))
+		if err := sleepCtx(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// postOnce performs a single HTTP attempt with the already-encoded body.
+func (p *OpenAIProvider) postOnce(ctx context.Context, path string, body []byte, stream bool) (*http.Response, error) {
+	url := p.APIBase + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
-		return LLMResponse{}, err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
 
 	resp, err := p.Client.Do(req)
 	if err != nil {
-		return LLMResponse{}, err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
 		// attempt to read response body for more details (do not expose API key)
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		body := strings.TrimSpace(string(bodyBytes))
 		log.Printf("OpenAI API non-2xx: %s body=%q", resp.Status, body)
-		if body == "" {
-			return LLMResponse{}, fmt.Errorf("OpenAI API error: %s", resp.Status)
+		return nil, &APIError{
+			Provider:   "OpenAI",
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+			RetryAfter: parseRetryAfter(resp.Header, time.Now()),
 		}
-		return LLMResponse{}, fmt.Errorf("OpenAI API error: %s - %s", resp.Status, body)
 	}
+	return resp, nil
+}
+
+// Chat calls an OpenAI-compatible chat completion endpoint and returns a simplified response.
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	if p.APIKey == "" {
+		return LLMResponse{}, errors.New("OpenAI provider: API key is not configured")
+	}
+	if model == "" {
+		model = p.GetDefaultModel()
+	}
+
+	req := p.buildRequest(messages, tools, model, opts)
+	resp, err := p.post(ctx, "/chat/completions", req, false)
+	var apiErr *APIError
+	if err != nil && req.ResponseFormat != nil && req.ResponseFormat.Type == "json_schema" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		// Many OpenAI-compatible servers don't support json_schema; the caller
+		// still validates the reply against the schema.
+		req.ResponseFormat = &responseFormatJSON{Type: "json_object"}
+		resp, err = p.post(ctx, "/chat/completions", req, false)
+		if err == nil && !p.noJSONSchema.Swap(true) {
+			log.Printf("OpenAI provider: %s rejected a json_schema response format; using json_object from now on", p.APIBase)
+		}
+	}
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer resp.Body.Close()
 
 	var out chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
@@ -172,10 +332,29 @@ func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []T
 	}
 
 	msg := out.Choices[0].Message
+	res := toLLMResponse(msg.Content, joinReasoning(msg.ReasoningContent, msg.Reasoning), msg.ToolCalls)
+	res.Usage = out.Usage.toUsage()
+	logEmptyReply(res, out.Choices[0].FinishReason)
+	return res, nil
+}
+
+func (u *usageJSON) toUsage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// toLLMResponse normalizes assistant content, reasoning and raw tool calls into
+// an LLMResponse. Inline <think> blocks are moved from content to Reasoning.
+func toLLMResponse(content, reasoning string, toolCalls []toolCallJSON) LLMResponse {
+	content, inline := splitThinking(content)
+	reasoning = joinReasoning(reasoning, inline)
+
 	// If the model requested tool calls, parse them
-	if len(msg.ToolCalls) > 0 {
+	if len(toolCalls) > 0 {
 		var tcs []ToolCall
-		for _, tc := range msg.ToolCalls {
+		for _, tc := range toolCalls {
 			var parsed map[string]interface{}
 			if err := json.Unmarshal([]byte(tc.Function.Arguments), &parsed); err != nil {
 				// skip unparseable tool calls
@@ -184,10 +363,136 @@ func (p *OpenAIProvider) Chat(ctx context.Context, messages []Message, tools []T
 			tcs = append(tcs, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: parsed})
 		}
 		if len(tcs) > 0 {
-			return LLMResponse{Content: strings.TrimSpace(msg.Content), HasToolCalls: true, ToolCalls: tcs}, nil
+			return LLMResponse{Content: strings.TrimSpace(content), HasToolCalls: true, ToolCalls: tcs, Reasoning: reasoning}
 		}
 	}
 
 	// No tool calls
-	return LLMResponse{Content: strings.TrimSpace(msg.Content), HasToolCalls: false}, nil
+	return LLMResponse{Content: strings.TrimSpace(content), HasToolCalls: false, Reasoning: reasoning}
+}
+
+// logEmptyReply explains the common reasoning-model failure of spending the
+// whole token budget on thinking and returning no visible reply.
+func logEmptyReply(res LLMResponse, finishReason string) {
+	if res.Content != "" || res.HasToolCalls || res.Reasoning == "" {
+		return
+	}
+	log.Printf("OpenAI API: model returned %d chars of reasoning but no reply (finish_reason=%q); consider raising maxTokens or lowering reasoningEffort", len(res.Reasoning), finishReason)
+}
+
+// streamChunk is one SSE "data:" payload of a streamed chat completion.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int                  `json:"index"`
+				ID       string               `json:"id"`
+				Type     string               `json:"type"`
+				Function toolCallFunctionJSON `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *usageJSON `json:"usage,omitempty"`
+}
+
+// ChatStream calls the chat completion endpoint with stream=true, forwarding text
+// deltas to onDelta and assembling tool calls from their incremental fragments.
+// Reasoning deltas and inline <think> blocks are collected but not forwarded.
+func (p *OpenAIProvider) ChatStream(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions, onDelta func(delta string)) (LLMResponse, error) {
+	if p.APIKey == "" {
+		return LLMResponse{}, errors.New("OpenAI provider: API key is not configured")
+	}
+	if model == "" {
+		model = p.GetDefaultModel()
+	}
+
+	reqBody := p.buildRequest(messages, tools, model, opts)
+	reqBody.Stream = true
+	reqBody.StreamOptions = &streamOptions{IncludeUsage: true}
+	resp, err := p.post(ctx, "/chat/completions", reqBody, true)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var content, reasoning strings.Builder
+	var usage Usage
+	var finishReason string
+	sent := 0 // bytes of visible content already passed to onDelta
+	// tool call fragments are keyed by their index within the choice
+	var calls []toolCallJSON
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, comments and event names
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("OpenAI stream: skipping malformed chunk: %v", err)
+			continue
+		}
+		if chunk.Usage != nil {
+			// sent as a final chunk with no choices when include_usage is set
+			usage = chunk.Usage.toUsage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if fr := chunk.Choices[0].FinishReason; fr != "" {
+			finishReason = fr
+		}
+		delta := chunk.Choices[0].Delta
+		reasoning.WriteString(delta.ReasoningContent)
+		reasoning.WriteString(delta.Reasoning)
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			if visible := streamVisible(content.String()); onDelta != nil && len(visible) > sent {
+				onDelta(visible[sent:])
+				sent = len(visible)
+			}
+		}
+		for _, tc := range delta.ToolCalls {
+			for len(calls) <= tc.Index {
+				calls = append(calls, toolCallJSON{Type: "function"})
+			}
+			c := &calls[tc.Index]
+			if tc.ID != "" {
+				c.ID = tc.ID
+			}
+			if tc.Function.Name != "" {
+				c.Function.Name = tc.Function.Name
+			}
+			c.Function.Arguments += tc.Function.Arguments
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return LLMResponse{}, err
+	}
+
+	res := toLLMResponse(content.String(), reasoning.String(), calls)
+	res.Usage = usage
+	logEmptyReply(res, finishReason)
+	return res, nil
+}
+
+// streamVisible returns the part of partially streamed content that may be
+// shown to the user: nothing while a leading <think> block (or its opening
+// tag) is still arriving, and the text after it once it has closed.
+func streamVisible(content string) string {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	if trimmed != "" && len(trimmed) < len(thinkOpen) && strings.HasPrefix(thinkOpen, trimmed) {
+		return ""
+	}
+	visible, _ := splitThinking(content)
+	return visible
 }
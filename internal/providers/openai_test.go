@@ -2,8 +2,11 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -43,7 +46,7 @@ func TestOpenAIFunctionCallParsing(t *testing.T) {
 	defer cancel()
 
 	msgs := []Message{{Role: "user", Content: "trigger"}}
-	resp, err := p.Chat(ctx, msgs, nil, "model-x")
+	resp, err := p.Chat(ctx, msgs, nil, "model-x", ChatOptions{})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -57,3 +60,225 @@ func TestOpenAIFunctionCallParsing(t *testing.T) {
 		t.Fatalf("unexpected argument content: %v", resp.ToolCalls[0].Arguments)
 	}
 }
+
+func TestOpenAIChatStreamAssemblesDeltas(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		chunks := []string{
+			`{"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"web","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"url\": "}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"https://x\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		}
+		for _, c := range chunks {
+			w.Write([]byte("data: " + c + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.Client = &http.Client{Timeout: 5 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var deltas []string
+	resp, err := p.ChatStream(ctx, []Message{{Role: "user", Content: "hi"}}, nil, "model-x", ChatOptions{}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(deltas) != 2 || deltas[0] != "Hel" || deltas[1] != "lo" {
+		t.Fatalf("unexpected deltas: %v", deltas)
+	}
+	if resp.Content != "Hello" {
+		t.Fatalf("expected assembled content 'Hello', got %q", resp.Content)
+	}
+	if !resp.HasToolCalls || len(resp.ToolCalls) != 1 {
+		t.Fatalf("expected one tool call, got: has=%v len=%d", resp.HasToolCalls, len(resp.ToolCalls))
+	}
+	if resp.ToolCalls[0].ID != "call_1" || resp.ToolCalls[0].Arguments["url"] != "https://x" {
+		t.Fatalf("unexpected tool call: %+v", resp.ToolCalls[0])
+	}
+}
+
+func TestOpenAIRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if calls == 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.RetryBaseDelay = time.Millisecond
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if resp.Content != "ok" || calls != 3 {
+		t.Fatalf("unexpected result: content=%q calls=%d", resp.Content, calls)
+	}
+}
+
+func TestOpenAITypedErrors(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"error":{"message":"invalid api key"}}`, ErrAuth},
+		{http.StatusBadRequest, `{"error":{"code":"context_length_exceeded"}}`, ErrContextLength},
+		{http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, ErrRateLimited},
+	}
+	for _, tc := range cases {
+		calls := 0
+		h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+		p := NewOpenAIProvider("test-key", h.URL, 60)
+		p.RetryBaseDelay = time.Millisecond
+
+		_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", ChatOptions{})
+		h.Close()
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("status %d: expected %v, got %v", tc.status, tc.want, err)
+		}
+		wantCalls := 1
+		if IsTransient(err) {
+			wantCalls = p.MaxRetries + 1
+		}
+		if calls != wantCalls {
+			t.Fatalf("status %d: expected %d attempts, got %d", tc.status, wantCalls, calls)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Retry-After", "7")
+	if d := parseRetryAfter(h, now); d != 7*time.Second {
+		t.Fatalf("expected 7s, got %v", d)
+	}
+	h.Set("Retry-After", now.Add(3*time.Second).Format(http.TimeFormat))
+	if d := parseRetryAfter(h, now); d != 3*time.Second {
+		t.Fatalf("expected 3s, got %v", d)
+	}
+	if _, ok := retryDelay(0, time.Second, time.Hour); ok {
+		t.Fatalf("expected an excessive Retry-After to be refused")
+	}
+}
+
+func TestOpenAIParsesUsage(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", ChatOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 3 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestOpenAIBuildRequestSerializesImageParts(t *testing.T) {
+	p := NewOpenAIProvider("test-key", "", 60)
+	req := p.buildRequest([]Message{{
+		Role:    "user",
+		Content: "look",
+		Parts: []ContentPart{
+			{Type: "text", Text: "look"},
+			{Type: "image_url", ImageURL: "https://example.com/cat.png"},
+		},
+	}}, nil, "m", ChatOptions{})
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `"content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]`
+	if !strings.Contains(string(b), want) {
+		t.Fatalf("expected %s in %s", want, b)
+	}
+}
+
+func TestOpenAIBuildRequestSendsOptions(t *testing.T) {
+	p := NewOpenAIProvider("test-key", "", 60)
+	temp, seed := 0.2, 7
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	req := p.buildRequest(msgs, nil, "m", ChatOptions{MaxTokens: 256, Temperature: &temp, Stop: []string{"END"}, Seed: &seed})
+	b, _ := json.Marshal(req)
+	for _, want := range []string{`"max_tokens":256`, `"temperature":0.2`, `"stop":["END"]`, `"seed":7`} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("expected %s in %s", want, b)
+		}
+	}
+
+	// reasoning models take max_completion_tokens and reject sampling parameters
+	req = p.buildRequest(msgs, nil, "o3-mini", ChatOptions{MaxTokens: 256, Temperature: &temp, ReasoningEffort: "low"})
+	b, _ = json.Marshal(req)
+	if !strings.Contains(string(b), `"max_completion_tokens":256`) || !strings.Contains(string(b), `"reasoning_effort":"low"`) {
+		t.Fatalf("expected reasoning parameters in %s", b)
+	}
+	if strings.Contains(string(b), "temperature") || strings.Contains(string(b), `"max_tokens"`) {
+		t.Fatalf("expected no temperature or max_tokens for reasoning request: %s", b)
+	}
+
+	// unset options are omitted entirely
+	b, _ = json.Marshal(p.buildRequest(msgs, nil, "m", ChatOptions{}))
+	if strings.Contains(string(b), "max_tokens") || strings.Contains(string(b), "temperature") {
+		t.Fatalf("expected no generation parameters, got %s", b)
+	}
+}
+
+func TestOpenAIFallsBackToJSONObject(t *testing.T) {
+	var formats []string
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, req.ResponseFormat.Type)
+		if req.ResponseFormat.Type == "json_schema" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"response_format json_schema is not supported"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{\"ok\": true}"}}]}`))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	format := ResponseFormat{Name: "check", Schema: map[string]interface{}{"type": "object"}}
+	for i := 0; i < 2; i++ {
+		var out map[string]interface{}
+		if _, err := ChatJSON(context.Background(), p, nil, "m", ChatOptions{}, format, &out); err != nil || out["ok"] != true {
+			t.Fatalf("call %d: expected the json_object retry to succeed, got %v, %v", i, out, err)
+		}
+	}
+	// the first call learns that json_schema is unsupported; the second skips it
+	want := []string{"json_schema", "json_object", "json_object"}
+	if strings.Join(formats, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected formats %v, got %v", want, formats)
+	}
+}
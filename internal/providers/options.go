@@ -0,0 +1,38 @@
+package providers
+
+import "github.com/local/picobot/internal/config"
+
+// DefaultChatOptions returns the generation options configured in agents.defaults.
+// Temperature stays nil, so it isn't sent, unless the config sets it.
+func DefaultChatOptions(d config.AgentDefaults) ChatOptions {
+	return ChatOptions{
+		MaxTokens:       d.MaxTokens,
+		Temperature:     d.Temperature,
+		ReasoningEffort: d.ReasoningEffort,
+	}
+}
+
+// ApplyProfile returns base with every field set in the profile overridden.
+// The profile's model is not part of ChatOptions and is left to the caller.
+func ApplyProfile(base ChatOptions, p config.ModelProfile) ChatOptions {
+	out := base
+	if p.MaxTokens > 0 {
+		out.MaxTokens = p.MaxTokens
+	}
+	if p.Temperature != nil {
+		out.Temperature = p.Temperature
+	}
+	if p.TopP != nil {
+		out.TopP = p.TopP
+	}
+	if len(p.Stop) > 0 {
+		out.Stop = p.Stop
+	}
+	if p.Seed != nil {
+		out.Seed = p.Seed
+	}
+	if p.ReasoningEffort != "" {
+		out.ReasoningEffort = p.ReasoningEffort
+	}
+	return out
+}
@@ -8,6 +8,17 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCallID string     `json:"tool_call_id,omitempty"` // set when Role == "tool"
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // set on assistant msgs with tool calls
+	// Parts, when set, replaces Content with multimodal content (text and images).
+	// Only user messages carry parts.
+	Parts []ContentPart `json:"parts,omitempty"`
+}
+
+// ContentPart is one piece of a multimodal message.
+type ContentPart struct {
+	Type string `json:"type"` // "text" | "image_url"
+	Text string `json:"text,omitempty"`
+	// ImageURL is an http(s) URL or a base64 data URL ("data:image/png;base64,...").
+	ImageURL string `json:"image_url,omitempty"`
 }
 
 // ToolDefinition is a lightweight description of a tool available to the model.
@@ -24,18 +35,69 @@ type ToolCall struct {
 	Arguments map[string]interface{} `json:"arguments"`
 }
 
+// Usage reports token counts for a single provider call, when the provider returns them.
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// Add accumulates u2 into u.
+func (u *Usage) Add(u2 Usage) {
+	u.PromptTokens += u2.PromptTokens
+	u.CompletionTokens += u2.CompletionTokens
+}
+
+// ChatOptions carries generation parameters for a single call. Zero values
+// (and nil pointers) mean "not set": the provider's own default is used and
+// the field is not sent.
+type ChatOptions struct {
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	// ReasoningEffort is "low", "medium" or "high" for models that support it.
+	ReasoningEffort string `json:"reasoningEffort,omitempty"`
+	// ResponseFormat requests a JSON reply; see ChatJSON.
+	ResponseFormat *ResponseFormat `json:"responseFormat,omitempty"`
+}
+
 // LLMResponse is a normalized response from a provider.
 type LLMResponse struct {
 	Content      string     `json:"content"`
 	HasToolCalls bool       `json:"hasToolCalls"`
 	ToolCalls    []ToolCall `json:"toolCalls,omitempty"`
+	Usage        Usage      `json:"usage"`
+	// Reasoning is the model's thinking output, for models that return it
+	// separately from the reply. It is for debugging and must not be shown to users.
+	Reasoning string `json:"reasoning,omitempty"`
+	// Model is the model that served the request when it may differ from the
+	// requested one, e.g. after a failover. Empty means the requested model.
+	Model string `json:"model,omitempty"`
 }
 
 // LLMProvider is the interface used by the agent loop to call LLMs.
 type LLMProvider interface {
 	// Chat sends messages to the model and returns a normalized response.
-	Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string) (LLMResponse, error)
+	Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error)
 
 	// GetDefaultModel returns the provider's default model string.
 	GetDefaultModel() string
 }
+
+// StreamingProvider is implemented by providers that can deliver partial output
+// while the model is still generating.
+type StreamingProvider interface {
+	LLMProvider
+
+	// ChatStream behaves like Chat but calls onDelta with each text fragment as it
+	// arrives. The returned response is the fully assembled result, including any
+	// tool calls that were streamed incrementally. onDelta may also receive
+	// StreamReset.
+	ChatStream(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions, onDelta func(delta string)) (LLMResponse, error)
+}
+
+// StreamReset is passed to onDelta instead of a text fragment when the text
+// delivered so far is discarded, e.g. because a stream failed partway and a
+// failover moved on to another provider. Later fragments start over.
+const StreamReset = "\x00reset"
@@ -0,0 +1,35 @@
+package providers
+
+import "strings"
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// splitThinking separates a leading <think>...</think> block, which DeepSeek-R1,
+// QwQ and similar models emit inline, from the visible reply. An unclosed block
+// (the reply was cut off while the model was still thinking) is all reasoning.
+func splitThinking(content string) (visible, reasoning string) {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	body, ok := strings.CutPrefix(trimmed, thinkOpen)
+	if !ok {
+		return content, ""
+	}
+	thought, after, closed := strings.Cut(body, thinkClose)
+	if !closed {
+		return "", strings.TrimSpace(body)
+	}
+	return strings.TrimLeft(after, " \t\r\n"), strings.TrimSpace(thought)
+}
+
+// joinReasoning concatenates reasoning from separate sources, skipping empty ones.
+func joinReasoning(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
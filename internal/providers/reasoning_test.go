@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitThinking(t *testing.T) {
+	cases := []struct{ in, visible, reasoning string }{
+		{"plain reply", "plain reply", ""},
+		{"<think>\nweigh options\n</think>\n\nAnswer", "Answer", "weigh options"},
+		{"  <think>cut off mid-thou", "", "cut off mid-thou"},
+		{"I <think> inline is not a block", "I <think> inline is not a block", ""},
+	}
+	for _, c := range cases {
+		v, r := splitThinking(c.in)
+		if v != c.visible || r != c.reasoning {
+			t.Fatalf("%q: got (%q, %q), want (%q, %q)", c.in, v, r, c.visible, c.reasoning)
+		}
+	}
+}
+
+func TestOpenAIParsesReasoning(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "reasoning_content": "step 1", "content": "<think>step 2</think>42"}, "finish_reason": "stop"}]}`))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.Client = &http.Client{Timeout: 5 * time.Second}
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", ChatOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if resp.Content != "42" || resp.Reasoning != "step 1\n\nstep 2" {
+		t.Fatalf("unexpected response: content=%q reasoning=%q", resp.Content, resp.Reasoning)
+	}
+}
+
+func TestOpenAIChatStreamHidesReasoning(t *testing.T) {
+	h := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"choices":[{"delta":{"reasoning":"thinking..."}}]}`,
+			`{"choices":[{"delta":{"content":"<thi"}}]}`,
+			`{"choices":[{"delta":{"content":"nk>hidden</think>"}}]}`,
+			`{"choices":[{"delta":{"content":"\nVisible"}}]}`,
+			`{"choices":[{"delta":{"content":" reply"},"finish_reason":"stop"}]}`,
+		}
+		for _, c := range chunks {
+			w.Write([]byte("data: " + c + "\n\n"))
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer h.Close()
+
+	p := NewOpenAIProvider("test-key", h.URL, 60)
+	p.Client = &http.Client{Timeout: 5 * time.Second}
+
+	var deltas []string
+	resp, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "m", ChatOptions{}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if got := strings.Join(deltas, ""); got != "Visible reply" {
+		t.Fatalf("expected only visible text to be streamed, got %q", got)
+	}
+	if resp.Content != "Visible reply" || resp.Reasoning != "thinking...\n\nhidden" {
+		t.Fatalf("unexpected response: content=%q reasoning=%q", resp.Content, resp.Reasoning)
+	}
+}
@@ -0,0 +1,150 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// ErrCassetteMiss is returned in replay mode when the cassette holds no (more)
+// responses for a request.
+var ErrCassetteMiss = errors.New("no recorded response for request")
+
+// RecordingProvider records provider interactions to a cassette file, or replays
+// them without network access. Requests are matched by a hash of the model,
+// messages, tool definitions and generation options, so a replay only succeeds if the agent sends
+// exactly what it sent while recording.
+//
+// In record mode every successful Chat call on the wrapped provider is appended
+// to the cassette, which is rewritten after each call. Errors are passed through
+// and not recorded. In replay mode the same request may be served several times
+// if it was recorded several times; responses come back in recording order.
+type RecordingProvider struct {
+	inner  LLMProvider // nil in replay mode
+	path   string
+	mu     sync.Mutex
+	tape   cassette
+	replay map[string][]LLMResponse // remaining responses per request key
+}
+
+// cassette is the on-disk format. Requests are stored alongside responses so
+// cassettes can be read and reviewed in diffs.
+type cassette struct {
+	DefaultModel string        `json:"defaultModel"`
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Key      string          `json:"key"`
+	Request  recordedRequest `json:"request"`
+	Response LLMResponse     `json:"response"`
+}
+
+type recordedRequest struct {
+	Model    string           `json:"model"`
+	Messages []Message        `json:"messages"`
+	Tools    []ToolDefinition `json:"tools,omitempty"`
+	Options  *ChatOptions     `json:"options,omitempty"` // nil when no options were set
+}
+
+// NewRecordingProvider wraps inner and records its responses to the cassette at
+// path, replacing any existing cassette.
+func NewRecordingProvider(inner LLMProvider, path string) *RecordingProvider {
+	return &RecordingProvider{
+		inner: inner,
+		path:  path,
+		tape:  cassette{DefaultModel: inner.GetDefaultModel()},
+	}
+}
+
+// NewReplayProvider loads the cassette at path and serves its recorded responses.
+func NewReplayProvider(path string) (*RecordingProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tape cassette
+	if err := json.Unmarshal(b, &tape); err != nil {
+		return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+	}
+	p := &RecordingProvider{path: path, tape: tape, replay: make(map[string][]LLMResponse)}
+	for _, it := range tape.Interactions {
+		p.replay[it.Key] = append(p.replay[it.Key], it.Response)
+	}
+	return p, nil
+}
+
+func (p *RecordingProvider) GetDefaultModel() string { return p.tape.DefaultModel }
+
+// Chat records or replays a single request.
+func (p *RecordingProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	req := recordedRequest{Model: model, Messages: messages, Tools: sortedTools(tools)}
+	if !reflect.DeepEqual(opts, ChatOptions{}) {
+		req.Options = &opts
+	}
+	key, err := requestKey(req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+
+	if p.inner == nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		queue := p.replay[key]
+		if len(queue) == 0 {
+			return LLMResponse{}, fmt.Errorf("%w (key %s) in %s", ErrCassetteMiss, key[:12], p.path)
+		}
+		p.replay[key] = queue[1:]
+		return queue[0], nil
+	}
+
+	resp, err := p.inner.Chat(ctx, messages, tools, model, opts)
+	if err != nil {
+		return resp, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tape.Interactions = append(p.tape.Interactions, interaction{Key: key, Request: req, Response: resp})
+	if err := p.save(); err != nil {
+		return resp, fmt.Errorf("writing cassette: %w", err)
+	}
+	return resp, nil
+}
+
+// requestKey returns the hex SHA-256 identifying a request in a cassette. Tools
+// are sorted by name before hashing so registration order does not matter.
+func requestKey(req recordedRequest) (string, error) {
+	// encoding/json sorts map keys, so tool arguments and schemas hash stably.
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func sortedTools(tools []ToolDefinition) []ToolDefinition {
+	out := append([]ToolDefinition(nil), tools...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// save writes the cassette; the caller must hold p.mu.
+func (p *RecordingProvider) save() error {
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(p.tape, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, b, 0o644)
+}
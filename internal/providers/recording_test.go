@@ -0,0 +1,50 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingProviderRecordsAndReplays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "chat.json")
+	msgs := []Message{{Role: "user", Content: "hi"}}
+	tools := []ToolDefinition{{Name: "web"}, {Name: "exec"}}
+
+	rec := NewRecordingProvider(&scriptedProvider{name: "live"}, path)
+	first, err := rec.Chat(context.Background(), msgs, tools, "m1", ChatOptions{})
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if _, err := rec.Chat(context.Background(), msgs, tools, "m1", ChatOptions{}); err != nil {
+		t.Fatalf("record second: %v", err)
+	}
+
+	rp, err := NewReplayProvider(path)
+	if err != nil {
+		t.Fatalf("load cassette: %v", err)
+	}
+	if rp.GetDefaultModel() != "live-default" {
+		t.Fatalf("expected recorded default model, got %q", rp.GetDefaultModel())
+	}
+	// tool order must not affect matching
+	reordered := []ToolDefinition{{Name: "exec"}, {Name: "web"}}
+	for i := 0; i < 2; i++ {
+		got, err := rp.Chat(context.Background(), msgs, reordered, "m1", ChatOptions{})
+		if err != nil {
+			t.Fatalf("replay %d: %v", i, err)
+		}
+		if got.Content != first.Content {
+			t.Fatalf("replay %d: expected %q, got %q", i, first.Content, got.Content)
+		}
+	}
+	// both recordings consumed
+	if _, err := rp.Chat(context.Background(), msgs, tools, "m1", ChatOptions{}); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("expected ErrCassetteMiss once exhausted, got %v", err)
+	}
+	// different request never matches
+	if _, err := rp.Chat(context.Background(), []Message{{Role: "user", Content: "bye"}}, tools, "m1", ChatOptions{}); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("expected ErrCassetteMiss for unknown request, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMaxRetries is used when the provider config does not set maxRetries.
+	defaultMaxRetries = 2
+	// defaultRetryBaseDelay is the first backoff step; it doubles per attempt.
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	// maxRetryDelay caps both the backoff and any Retry-After the server asks for.
+	// A longer Retry-After is not waited out; the error is returned instead.
+	maxRetryDelay = 30 * time.Second
+)
+
+// retryDelay returns how long to wait before retry number attempt (0-based).
+// A server-provided Retry-After wins; otherwise the delay is exponential with
+// jitter in [d/2, d) so concurrent clients do not retry in lockstep.
+// ok is false when the server asks for a longer wait than maxRetryDelay.
+func retryDelay(attempt int, base, retryAfter time.Duration) (d time.Duration, ok bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= maxRetryDelay
+	}
+	d = base << attempt
+	if d <= 0 || d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1), true
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// sleepCtx waits for d or until ctx is done, returning ctx.Err() in the latter case.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
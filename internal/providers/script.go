@@ -0,0 +1,162 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ScriptProvider replies according to a scenario file instead of calling a model,
+// so tools, skills, cron and channels can be demoed and tested offline.
+//
+// Each rule matches the latest user message with a regular expression and lists
+// the steps of the turn. The current step is the number of assistant tool-call
+// rounds since that user message: step 0 answers the user, step 1 answers the
+// first round of tool results, and so on. After the last step the provider
+// repeats the last step if it is a plain reply, or returns Fallback otherwise.
+//
+// In step content and string tool arguments, $1 / ${name} expand to the rule's
+// capture groups ($$ for a literal dollar sign) and {{lastToolResult}} expands
+// to the most recent tool result.
+type ScriptProvider struct {
+	scenario Scenario
+	rules    []*regexp.Regexp
+}
+
+// Scenario is the JSON scenario file format.
+type Scenario struct {
+	DefaultModel string       `json:"defaultModel,omitempty"`
+	Rules        []ScriptRule `json:"rules"`
+	// Fallback is the reply when no rule matches, or when a rule's steps run
+	// out on a tool-calling step. Empty replies that no rule matched, quoting
+	// the message.
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// ScriptRule maps a user message pattern to the steps of a turn.
+type ScriptRule struct {
+	Match string       `json:"match"`
+	Steps []ScriptStep `json:"steps"`
+}
+
+// ScriptStep is one model response: tool calls, plain content, or both.
+type ScriptStep struct {
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []ScriptToolCall `json:"toolCalls,omitempty"`
+}
+
+// ScriptToolCall is a tool invocation in a scenario step.
+type ScriptToolCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+}
+
+// NewScriptProvider loads and validates the scenario at path.
+func NewScriptProvider(path string) (*ScriptProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	return NewScriptProviderFromScenario(sc)
+}
+
+// NewScriptProviderFromScenario builds a provider from an in-memory scenario.
+func NewScriptProviderFromScenario(sc Scenario) (*ScriptProvider, error) {
+	p := &ScriptProvider{scenario: sc}
+	for i, r := range sc.Rules {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid match pattern: %w", i, err)
+		}
+		if len(r.Steps) == 0 {
+			return nil, fmt.Errorf("rule %d (%q): no steps", i, r.Match)
+		}
+		p.rules = append(p.rules, re)
+	}
+	return p, nil
+}
+
+func (p *ScriptProvider) GetDefaultModel() string {
+	if p.scenario.DefaultModel != "" {
+		return p.scenario.DefaultModel
+	}
+	return "script"
+}
+
+// Chat picks the matching rule and the step for the current tool-call round.
+func (p *ScriptProvider) Chat(ctx context.Context, messages []Message, tools []ToolDefinition, model string, opts ChatOptions) (LLMResponse, error) {
+	lastUser := -1
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			lastUser = i
+			break
+		}
+	}
+	userText := ""
+	if lastUser >= 0 {
+		userText = messages[lastUser].Content
+	}
+	step, lastToolResult := 0, ""
+	for _, m := range messages[lastUser+1:] {
+		if m.Role == "assistant" && len(m.ToolCalls) > 0 {
+			step++
+		}
+		if m.Role == "tool" {
+			lastToolResult = m.Content
+		}
+	}
+
+	for i, re := range p.rules {
+		match := re.FindStringSubmatchIndex(userText)
+		if match == nil {
+			continue
+		}
+		steps := p.scenario.Rules[i].Steps
+		if step >= len(steps) {
+			last := steps[len(steps)-1]
+			if len(last.ToolCalls) > 0 {
+				return LLMResponse{Content: p.fallback(userText)}, nil
+			}
+			step = len(steps) - 1
+		}
+		expand := func(tmpl string) string {
+			out := string(re.ExpandString(nil, tmpl, userText, match))
+			return strings.ReplaceAll(out, "{{lastToolResult}}", lastToolResult)
+		}
+		return buildScriptResponse(steps[step], expand), nil
+	}
+	return LLMResponse{Content: p.fallback(userText)}, nil
+}
+
+func (p *ScriptProvider) fallback(userText string) string {
+	if p.scenario.Fallback != "" {
+		return p.scenario.Fallback
+	}
+	return fmt.Sprintf("(script) No rule matched: %s", userText)
+}
+
+// buildScriptResponse renders a step. Tool call IDs are random, like a real
+// model's, so calls replayed from session history never collide.
+func buildScriptResponse(s ScriptStep, expand func(string) string) LLMResponse {
+	resp := LLMResponse{Content: expand(s.Content)}
+	for _, tc := range s.ToolCalls {
+		args := make(map[string]interface{}, len(tc.Arguments))
+		for k, v := range tc.Arguments {
+			if str, ok := v.(string); ok {
+				v = expand(str)
+			}
+			args[k] = v
+		}
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: fmt.Sprintf("script_%016x", rand.Uint64()), Name: tc.Name, Arguments: args})
+	}
+	resp.HasToolCalls = len(resp.ToolCalls) > 0
+	return resp
+}
@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScriptProviderFollowsSteps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	os.WriteFile(path, []byte(`{
+	  "rules": [
+	    {
+	      "match": "(?i)weather in (\\w+)",
+	      "steps": [
+	        {"toolCalls": [{"name": "web", "arguments": {"url": "https://wttr.in/$1?format=3", "retries": 2}}]},
+	        {"content": "Forecast: {{lastToolResult}}"}
+	      ]
+	    }
+	  ],
+	  "fallback": "no script"
+	}`), 0o644)
+	p, err := NewScriptProvider(path)
+	if err != nil {
+		t.Fatalf("load scenario: %v", err)
+	}
+	ctx := context.Background()
+
+	msgs := []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "What's the weather in Paris?"}}
+	resp, _ := p.Chat(ctx, msgs, nil, "", ChatOptions{})
+	if !resp.HasToolCalls || resp.ToolCalls[0].Name != "web" {
+		t.Fatalf("expected web tool call, got %+v", resp)
+	}
+	if resp.ToolCalls[0].Arguments["url"] != "https://wttr.in/Paris?format=3" || resp.ToolCalls[0].Arguments["retries"] != float64(2) {
+		t.Fatalf("unexpected arguments: %v", resp.ToolCalls[0].Arguments)
+	}
+
+	msgs = append(msgs,
+		Message{Role: "assistant", ToolCalls: resp.ToolCalls},
+		Message{Role: "tool", Content: "Paris: +18°C", ToolCallID: resp.ToolCalls[0].ID},
+	)
+	resp, _ = p.Chat(ctx, msgs, nil, "", ChatOptions{})
+	if resp.HasToolCalls || resp.Content != "Forecast: Paris: +18°C" {
+		t.Fatalf("expected final reply, got %+v", resp)
+	}
+
+	resp, _ = p.Chat(ctx, []Message{{Role: "user", Content: "hello"}}, nil, "", ChatOptions{})
+	if resp.Content != "no script" {
+		t.Fatalf("expected fallback, got %q", resp.Content)
+	}
+}
+
+func TestScriptProviderRejectsBadPattern(t *testing.T) {
+	if _, err := NewScriptProviderFromScenario(Scenario{Rules: []ScriptRule{{Match: "(", Steps: []ScriptStep{{Content: "x"}}}}}); err == nil {
+		t.Fatalf("expected invalid regexp to be rejected")
+	}
+}
+
+func TestScriptProviderToolCallIDsAreUniqueAcrossTurns(t *testing.T) {
+	p, err := NewScriptProviderFromScenario(Scenario{Rules: []ScriptRule{{Match: "go", Steps: []ScriptStep{{ToolCalls: []ScriptToolCall{{Name: "a"}, {Name: "b"}}}}}}})
+	if err != nil {
+		t.Fatalf("load scenario: %v", err)
+	}
+	seen := map[string]bool{}
+	for turn := 0; turn < 2; turn++ {
+		resp, _ := p.Chat(context.Background(), []Message{{Role: "user", Content: "go"}}, nil, "", ChatOptions{})
+		for _, tc := range resp.ToolCalls {
+			if seen[tc.ID] {
+				t.Fatalf("tool call ID %q repeated", tc.ID)
+			}
+			seen[tc.ID] = true
+		}
+	}
+}
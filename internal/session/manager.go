@@ -4,19 +4,88 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/local/picobot/internal/providers"
 )
 
 // MaxHistorySize is the maximum number of messages kept in a session.
 // Older messages are trimmed on save to keep the session file small
-// and avoid blowing up the LLM context window.
+// and avoid blowing up the LLM context window. Normally they are folded into
+// the summary first (see SummarizeThreshold); trimming is the backstop when
+// summarizing fails.
 // Important information should be persisted via write_memory, not session history.
 const MaxHistorySize = 50
 
+// SummarizeThreshold is the history length at which the oldest
+// SummarizeChunk messages should be folded into the session's summary.
+const (
+	SummarizeThreshold = 40
+	SummarizeChunk     = 20
+)
+
 // Session holds a short chat history.
 type Session struct {
 	Key     string
-	History []string
+	History []Message
+	// Summary is a rolling summary of the messages that were folded out of History.
+	Summary string `json:",omitempty"`
+}
+
+// Message is one entry of a session's history, kept with its role so it can
+// be replayed to the model as it happened.
+type Message struct {
+	Role    string `json:"role"` // "user" | "assistant" | "tool"
+	Content string `json:"content"`
+	// ToolCalls are the calls an assistant message made; ToolCallID links a
+	// tool message to the call it answers.
+	ToolCalls  []providers.ToolCall `json:"toolCalls,omitempty"`
+	ToolCallID string               `json:"toolCallId,omitempty"`
+	// SenderID and Metadata come from the inbound message (user messages only).
+	SenderID string                 `json:"senderId,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Time     time.Time              `json:"time"`
+}
+
+// UnmarshalJSON reads both the current format and session files written
+// before history was typed, whose entries are "role: content" strings.
+func (s *Session) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Key     string
+		History []json.RawMessage
+		Summary string
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	s.Key, s.Summary = raw.Key, raw.Summary
+	s.History = make([]Message, 0, len(raw.History))
+	for _, item := range raw.History {
+		var legacy string
+		if json.Unmarshal(item, &legacy) == nil {
+			s.History = append(s.History, parseLegacy(legacy))
+			continue
+		}
+		var m Message
+		if err := json.Unmarshal(item, &m); err != nil {
+			return err
+		}
+		s.History = append(s.History, m)
+	}
+	return nil
+}
+
+// parseLegacy converts an old "role: content" history entry.
+func parseLegacy(entry string) Message {
+	if role, content, ok := strings.Cut(entry, ": "); ok {
+		switch role {
+		case "user", "assistant":
+			return Message{Role: role, Content: content}
+		}
+	}
+	return Message{Role: "user", Content: entry}
 }
 
 // SessionManager stores sessions in memory and persists to disk under workspace.
@@ -36,7 +105,7 @@ func (sm *SessionManager) GetOrCreate(key string) *Session {
 	if s, ok := sm.sessions[key]; ok {
 		return s
 	}
-	s := &Session{Key: key, History: make([]string, 0)}
+	s := &Session{Key: key, History: make([]Message, 0)}
 	sm.sessions[key] = s
 	return s
 }
@@ -82,18 +151,55 @@ func (sm *SessionManager) LoadAll() error {
 	return nil
 }
 
+// AddMessage appends a plain message with the current time.
 func (s *Session) AddMessage(role, content string) {
-	s.History = append(s.History, role+": "+content)
+	s.Add(Message{Role: role, Content: content})
+}
+
+// Add appends m, stamping it with the current time if it has none.
+func (s *Session) Add(m Message) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	s.History = append(s.History, m)
 }
 
 // GetHistory returns the session history.
-func (s *Session) GetHistory() []string {
+func (s *Session) GetHistory() []Message {
 	return s.History
 }
 
-// trim keeps only the last MaxHistorySize messages, discarding the oldest.
+// NeedsSummary reports whether the history has grown past SummarizeThreshold.
+func (s *Session) NeedsSummary() bool {
+	return len(s.History) >= SummarizeThreshold
+}
+
+// OldestChunk returns the oldest messages to fold into the summary: about
+// SummarizeChunk of them, more if that would split a tool call from its results.
+func (s *Session) OldestChunk() []Message {
+	return s.History[:s.boundary(SummarizeChunk)]
+}
+
+// ApplySummary replaces the summary and drops the n oldest messages it now covers.
+func (s *Session) ApplySummary(summary string, n int) {
+	s.Summary = summary
+	s.History = append([]Message(nil), s.History[min(n, len(s.History)):]...)
+}
+
+// trim keeps only the last MaxHistorySize messages (or slightly fewer, so the
+// history doesn't start with orphaned tool results), discarding the oldest.
 func (s *Session) trim() {
 	if len(s.History) > MaxHistorySize {
-		s.History = s.History[len(s.History)-MaxHistorySize:]
+		s.History = s.History[s.boundary(len(s.History)-MaxHistorySize):]
+	}
+}
+
+// boundary returns the smallest index >= n, capped at the history length,
+// that doesn't fall between a tool-calling assistant message and its results.
+func (s *Session) boundary(n int) int {
+	n = min(n, len(s.History))
+	for n < len(s.History) && s.History[n].Role == "tool" {
+		n++
 	}
+	return n
 }
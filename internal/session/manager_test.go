@@ -0,0 +1,73 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/local/picobot/internal/providers"
+)
+
+func TestLoadAllMigratesLegacyHistory(t *testing.T) {
+	ws := t.TempDir()
+	dir := filepath.Join(ws, "sessions")
+	os.MkdirAll(dir, 0o755)
+	legacy := `{"Key": "telegram:1", "History": ["user: hi there", "assistant: hello: how can I help?", "no role"]}`
+	if err := os.WriteFile(filepath.Join(dir, "telegram:1.json"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := NewSessionManager(ws)
+	if err := sm.LoadAll(); err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	s := sm.GetOrCreate("telegram:1")
+	want := []Message{
+		{Role: "user", Content: "hi there"},
+		{Role: "assistant", Content: "hello: how can I help?"},
+		{Role: "user", Content: "no role"},
+	}
+	if len(s.History) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), s.History)
+	}
+	for i, w := range want {
+		if s.History[i].Role != w.Role || s.History[i].Content != w.Content {
+			t.Fatalf("message %d: expected %+v, got %+v", i, w, s.History[i])
+		}
+	}
+
+	// saving writes the typed format, which loads back unchanged
+	s.Add(Message{Role: "tool", Content: "42", ToolCallID: "call_1"})
+	if err := sm.Save(s); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	sm2 := NewSessionManager(ws)
+	if err := sm2.LoadAll(); err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	got := sm2.GetOrCreate("telegram:1").History
+	if len(got) != 4 || got[3].Role != "tool" || got[3].ToolCallID != "call_1" || got[3].Time.IsZero() {
+		t.Fatalf("unexpected history after round trip: %+v", got)
+	}
+}
+
+func TestOldestChunkKeepsToolResultsWithTheirCall(t *testing.T) {
+	s := &Session{Key: "telegram:1"}
+	for i := 0; i < SummarizeChunk-1; i++ {
+		s.AddMessage("user", "hi")
+	}
+	// the chunk would end right after this call, before its results
+	s.Add(Message{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "a", Name: "web"}, {ID: "b", Name: "web"}}})
+	s.Add(Message{Role: "tool", Content: "1", ToolCallID: "a"})
+	s.Add(Message{Role: "tool", Content: "2", ToolCallID: "b"})
+	s.AddMessage("assistant", "done")
+
+	chunk := s.OldestChunk()
+	if len(chunk) != SummarizeChunk+2 {
+		t.Fatalf("expected the chunk to include both tool results, got %d messages", len(chunk))
+	}
+	s.ApplySummary("summary", len(chunk))
+	if len(s.History) != 1 || s.History[0].Role != "assistant" {
+		t.Fatalf("expected only the final reply to remain, got %+v", s.History)
+	}
+}
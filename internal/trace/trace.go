@@ -0,0 +1,216 @@
+// Package trace records structured events for each agent turn to the
+// workspace and renders them for the `picobot trace show` command.
+package trace
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Event kinds, in the order they usually occur within a turn.
+const (
+	KindInbound  = "inbound"  // message received
+	KindContext  = "context"  // prompt built
+	KindProvider = "provider" // one model call
+	KindTool     = "tool"     // one tool call
+	KindOutbound = "outbound" // reply sent
+)
+
+// Event is one step of a turn. Fields not relevant to its Kind are left empty.
+// For provider and tool events, Time is when the call started.
+type Event struct {
+	Time    time.Time `json:"time"`
+	TurnID  string    `json:"turnId"`
+	Kind    string    `json:"kind"`
+	Channel string    `json:"channel"`
+	ChatID  string    `json:"chatId"`
+
+	Text       string `json:"text,omitempty"` // message text (inbound, outbound), truncated
+	Model      string `json:"model,omitempty"`
+	Tool       string `json:"tool,omitempty"`
+	Args       string `json:"args,omitempty"` // tool arguments as JSON, truncated
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	// context
+	Messages  int `json:"messages,omitempty"`
+	EstTokens int `json:"estTokens,omitempty"` // estimated prompt size
+
+	// provider
+	PromptTokens     int `json:"promptTokens,omitempty"`
+	CompletionTokens int `json:"completionTokens,omitempty"`
+	ToolCalls        int `json:"toolCalls,omitempty"`
+}
+
+// MaxTextLen caps the message text and tool arguments kept in an event.
+const MaxTextLen = 500
+
+// Truncate shortens s to at most MaxTextLen bytes, without splitting a UTF-8
+// character.
+func Truncate(s string) string {
+	if len(s) <= MaxTextLen {
+		return s
+	}
+	n := MaxTextLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// Tracer appends events to workspace/traces/YYYY-MM-DD.jsonl.
+type Tracer struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewTracer creates a tracer that writes under workspace/traces.
+func NewTracer(workspace string) *Tracer {
+	return &Tracer{dir: filepath.Join(workspace, "traces")}
+}
+
+// Record appends e to the file for its day. A zero Time is set to now.
+func (t *Tracer) Record(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	name := e.Time.UTC().Format("2006-01-02") + ".jsonl"
+	f, err := os.OpenFile(filepath.Join(t.dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// LoadTurn reads the events of one turn from workspace/traces, ordered by
+// time. Malformed lines are skipped.
+func LoadTurn(workspace, turnID string) ([]Event, error) {
+	dir := filepath.Join(workspace, "traces")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Event
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			// cheap filter before decoding
+			if !strings.Contains(sc.Text(), `"turnId":"`+turnID+`"`) {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || ev.TurnID != turnID {
+				continue
+			}
+			out = append(out, ev)
+		}
+		f.Close()
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// LastTurnID returns the ID of the most recently recorded turn, or "" if
+// there are no traces.
+func LastTurnID(workspace string) (string, error) {
+	dir := filepath.Join(workspace, "traces")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	// ReadDir sorts by name, so the newest day comes last.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].IsDir() || !strings.HasSuffix(entries[i].Name(), ".jsonl") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entries[i].Name()))
+		if err != nil {
+			return "", err
+		}
+		var last string
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			var ev Event
+			if json.Unmarshal(sc.Bytes(), &ev) == nil && ev.TurnID != "" {
+				last = ev.TurnID
+			}
+		}
+		f.Close()
+		if last != "" {
+			return last, nil
+		}
+	}
+	return "", nil
+}
+
+// Render writes events as a timeline, each line offset from the first event.
+func Render(w io.Writer, events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	first := events[0]
+	fmt.Fprintf(w, "turn %s  %s:%s  %s\n", first.TurnID, first.Channel, first.ChatID, first.Time.UTC().Format("2006-01-02 15:04:05 UTC"))
+	for _, e := range events {
+		offset := e.Time.Sub(first.Time).Seconds()
+		fmt.Fprintf(w, "%+9.3fs  %-8s  %s\n", offset, e.Kind, describe(e))
+	}
+}
+
+// describe renders the kind-specific part of a timeline line.
+func describe(e Event) string {
+	var s string
+	switch e.Kind {
+	case KindInbound, KindOutbound:
+		s = fmt.Sprintf("%q", e.Text)
+	case KindContext:
+		s = fmt.Sprintf("%d messages, ~%d tokens", e.Messages, e.EstTokens)
+	case KindProvider:
+		s = fmt.Sprintf("%s  %dms  %d+%d tokens", e.Model, e.DurationMs, e.PromptTokens, e.CompletionTokens)
+		if e.ToolCalls > 0 {
+			s += fmt.Sprintf("  %d tool call(s)", e.ToolCalls)
+		}
+	case KindTool:
+		s = fmt.Sprintf("%s %s  %dms", e.Tool, e.Args, e.DurationMs)
+	default:
+		s = e.Text
+	}
+	if e.Error != "" {
+		s += "  error: " + e.Error
+	}
+	return s
+}
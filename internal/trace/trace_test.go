@@ -0,0 +1,55 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestTracerRecordLoadAndRender(t *testing.T) {
+	ws := t.TempDir()
+	tr := NewTracer(ws)
+	start := time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC)
+	events := []Event{
+		// written out of order: the provider event is recorded when the call ends
+		{Time: start.Add(2 * time.Second), TurnID: "turn-a-1", Kind: KindOutbound, Text: "done"},
+		{Time: start, TurnID: "turn-a-1", Kind: KindInbound, Channel: "telegram", ChatID: "1", Text: "hi"},
+		{Time: start.Add(time.Second), TurnID: "turn-a-1", Kind: KindProvider, Model: "gpt-4o", DurationMs: 900, PromptTokens: 100, CompletionTokens: 5},
+		{Time: start.Add(time.Second), TurnID: "turn-a-2", Kind: KindInbound, Text: "other turn"},
+		{Time: start.Add(1500 * time.Millisecond), TurnID: "turn-a-1", Kind: KindTool, Tool: "exec", Args: `{"cmd":"ls"}`, Error: "boom"},
+	}
+	for _, e := range events {
+		if err := tr.Record(e); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	got, err := LoadTurn(ws, "turn-a-1")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 4 || got[0].Kind != KindInbound || got[3].Kind != KindOutbound {
+		t.Fatalf("expected the turn's 4 events in time order (across days), got %+v", got)
+	}
+	if last, _ := LastTurnID(ws); last != "turn-a-1" {
+		t.Fatalf("expected the last recorded turn, got %q", last)
+	}
+
+	var buf bytes.Buffer
+	Render(&buf, got)
+	out := buf.String()
+	for _, want := range []string{"turn turn-a-1  telegram:1", `+0.000s  inbound   "hi"`, "gpt-4o  900ms  100+5 tokens", `exec {"cmd":"ls"}`, "error: boom", "+2.000s  outbound"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestTruncateKeepsWholeCharacters(t *testing.T) {
+	got := Truncate("x" + strings.Repeat("é", MaxTextLen)) // a character straddles the cap
+	if !utf8.ValidString(got) || len(got) > MaxTextLen+len("...") || !strings.HasSuffix(got, "é...") {
+		t.Fatalf("expected a cut between characters, got %d bytes ending %q", len(got), got[len(got)-8:])
+	}
+}
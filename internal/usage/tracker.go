@@ -0,0 +1,185 @@
+// Package usage records per-turn token usage to the workspace and summarizes
+// it for the `picobot usage` command.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one agent turn's token usage. A turn may span several provider
+// calls when tools are used; their counts are summed per model, so a turn
+// that failed over to another model has a record for each. Work done for the
+// turn outside its own calls, such as memory ranking and summarizing, is
+// recorded separately under the same TurnID.
+type Record struct {
+	Time             time.Time `json:"time"`
+	Channel          string    `json:"channel"`
+	ChatID           string    `json:"chatId"`
+	TurnID           string    `json:"turnId,omitempty"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+}
+
+// Day returns the UTC date of the record in YYYY-MM-DD form.
+func (r Record) Day() string { return r.Time.UTC().Format("2006-01-02") }
+
+// Tracker appends records to workspace/usage/YYYY-MM-DD.jsonl.
+type Tracker struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewTracker creates a tracker that writes under workspace/usage.
+func NewTracker(workspace string) *Tracker {
+	return &Tracker{dir: filepath.Join(workspace, "usage")}
+}
+
+// Record appends r to the file for its day. A zero Time is set to now.
+func (t *Tracker) Record(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(t.dir, r.Day()+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// Load reads all records from workspace/usage for the last `days` days
+// (including today). days <= 0 loads every file. Malformed lines are skipped.
+func Load(workspace string, days int) ([]Record, error) {
+	dir := filepath.Join(workspace, "usage")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cutoff := ""
+	if days > 0 {
+		cutoff = time.Now().UTC().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	}
+	var out []Record
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		if cutoff != "" && strings.TrimSuffix(name, ".jsonl") < cutoff {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var r Record
+			if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+				continue
+			}
+			out = append(out, r)
+		}
+		f.Close()
+	}
+	return out, nil
+}
+
+// Price is the cost in USD per million input (prompt) and output (completion) tokens.
+type Price struct {
+	InputPerMTok  float64
+	OutputPerMTok float64
+}
+
+// Cost returns the estimated cost of r, and false if the model has no price.
+func Cost(r Record, prices map[string]Price) (float64, bool) {
+	p, ok := prices[r.Model]
+	if !ok {
+		return 0, false
+	}
+	return (float64(r.PromptTokens)*p.InputPerMTok + float64(r.CompletionTokens)*p.OutputPerMTok) / 1e6, true
+}
+
+// Total aggregates records sharing a key.
+type Total struct {
+	Key string
+	// Turns counts distinct turn IDs; a record without one (as written by
+	// older versions) counts as a turn of its own.
+	Turns            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	// Unpriced is true if any record in the group used a model with no price.
+	Unpriced bool
+}
+
+// KeyFuncs maps a --by value to the grouping key it produces.
+var KeyFuncs = map[string]func(Record) string{
+	"chat":    func(r Record) string { return r.Channel + ":" + r.ChatID },
+	"channel": func(r Record) string { return r.Channel },
+	"model":   func(r Record) string { return r.Model },
+	"day":     Record.Day,
+}
+
+// Summarize groups records by key and returns totals sorted by cost, then
+// total tokens, descending.
+func Summarize(records []Record, key func(Record) string, prices map[string]Price) []Total {
+	byKey := map[string]*Total{}
+	turns := map[string]bool{} // key + " " + turn ID
+	for _, r := range records {
+		k := key(r)
+		t, ok := byKey[k]
+		if !ok {
+			t = &Total{Key: k}
+			byKey[k] = t
+		}
+		if r.TurnID == "" || !turns[k+" "+r.TurnID] {
+			t.Turns++
+			turns[k+" "+r.TurnID] = true
+		}
+		t.PromptTokens += r.PromptTokens
+		t.CompletionTokens += r.CompletionTokens
+		if c, ok := Cost(r, prices); ok {
+			t.Cost += c
+		} else {
+			t.Unpriced = true
+		}
+	}
+	out := make([]Total, 0, len(byKey))
+	for _, t := range byKey {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost > out[j].Cost
+		}
+		ti := out[i].PromptTokens + out[i].CompletionTokens
+		tj := out[j].PromptTokens + out[j].CompletionTokens
+		if ti != tj {
+			return ti > tj
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
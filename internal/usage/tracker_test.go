@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerRecordAndSummarize(t *testing.T) {
+	ws := t.TempDir()
+	tr := NewTracker(ws)
+	now := time.Now().UTC()
+	recs := []Record{
+		{Time: now, Channel: "telegram", ChatID: "1", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 100},
+		{Time: now, Channel: "telegram", ChatID: "1", Model: "gpt-4o", PromptTokens: 2000, CompletionTokens: 200},
+		{Time: now, Channel: "heartbeat", ChatID: "system", Model: "mystery", PromptTokens: 500, CompletionTokens: 50},
+	}
+	for _, r := range recs {
+		if err := tr.Record(r); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	got, err := Load(ws, 1)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(got))
+	}
+
+	prices := map[string]Price{"gpt-4o": {InputPerMTok: 2.5, OutputPerMTok: 10}}
+	totals := Summarize(got, KeyFuncs["chat"], prices)
+	if len(totals) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(totals))
+	}
+	top := totals[0]
+	if top.Key != "telegram:1" || top.Turns != 2 || top.PromptTokens != 3000 || top.CompletionTokens != 300 {
+		t.Fatalf("unexpected top total: %+v", top)
+	}
+	// 3000*2.5/1e6 + 300*10/1e6
+	if want := 0.0105; top.Cost < want-1e-9 || top.Cost > want+1e-9 {
+		t.Fatalf("expected cost %v, got %v", want, top.Cost)
+	}
+	if !totals[1].Unpriced {
+		t.Fatalf("expected heartbeat group to be flagged unpriced")
+	}
+}
+
+func TestSummarizeCountsDistinctTurns(t *testing.T) {
+	recs := []Record{
+		{ChatID: "1", TurnID: "a", Model: "gpt-4o"},
+		{ChatID: "1", TurnID: "a", Model: "mini"}, // same turn, ranking or failover
+		{ChatID: "1", TurnID: "b", Model: "gpt-4o"},
+		{ChatID: "1", Model: "gpt-4o"}, // written before turn IDs
+	}
+	if got := Summarize(recs, KeyFuncs["chat"], nil)[0].Turns; got != 3 {
+		t.Fatalf("expected 3 turns for the chat, got %d", got)
+	}
+	for _, total := range Summarize(recs, KeyFuncs["model"], nil) {
+		if want := map[string]int{"gpt-4o": 3, "mini": 1}[total.Key]; total.Turns != want {
+			t.Fatalf("expected %d turns for %s, got %d", want, total.Key, total.Turns)
+		}
+	}
+}